@@ -213,12 +213,93 @@ func (t *Tools) Slugify(s string) (string, error) {
 // disk rather than rendering the file in the browser.
 // See https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Content-Disposition for further details
 // about the Content-Disposition header.
+// file must be located below path; requests for files outside of it (e.g. "../../etc/passwd" or symbolic
+// links pointing elsewhere) are answered with 403 Forbidden, missing files and directories with 404 Not Found.
 func (t *Tools) DownloadStaticFile(w http.ResponseWriter, r *http.Request, path, file, displayName string) {
-	filePath := filepath.Join(path, file)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", displayName))
+	filePath, status := resolveBelow(path, file)
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.Header().Set("Content-Disposition", contentDisposition(displayName))
 	http.ServeFile(w, r, filePath)
 }
 
+// contentDisposition builds an attachment Content-Disposition header value according to RFC 6266.
+// Quotes and backslashes are escaped. Non-ASCII file names are sent as UTF-8 encoded filename* parameter
+// (RFC 5987) with an ASCII fallback in which every other character is replaced by an underscore.
+func contentDisposition(filename string) string {
+	const hex = "0123456789ABCDEF"
+
+	var fallback, encoded strings.Builder
+	ascii := true
+	for _, r := range filename {
+		if r < 0x20 || r > 0x7e {
+			ascii = false
+			fallback.WriteByte('_')
+			continue
+		}
+		if r == '"' || r == '\\' {
+			fallback.WriteByte('\\')
+		}
+		fallback.WriteRune(r)
+	}
+	if ascii {
+		return `attachment; filename="` + fallback.String() + `"`
+	}
+
+	for i := 0; i < len(filename); i++ {
+		c := filename[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			encoded.WriteByte(c)
+			continue
+		}
+		encoded.WriteByte('%')
+		encoded.WriteByte(hex[c>>4])
+		encoded.WriteByte(hex[c&0x0f])
+	}
+	return `attachment; filename="` + fallback.String() + `"; filename*=UTF-8''` + encoded.String()
+}
+
+// resolveBelow returns the real path of file below root, resolving symbolic links. If file is outside
+// of root or isn't a regular file, an HTTP error status is returned instead of http.StatusOK.
+func resolveBelow(root, file string) (string, int) {
+	root, err := filepath.Abs(root)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return "", http.StatusNotFound
+	}
+
+	filePath := filepath.Join(root, filepath.FromSlash(file))
+	if !isBelow(root, filePath) {
+		return "", http.StatusForbidden
+	}
+	filePath, err = filepath.EvalSymlinks(filePath)
+	if err != nil {
+		return "", http.StatusNotFound
+	}
+	if !isBelow(root, filePath) {
+		return "", http.StatusForbidden
+	}
+
+	fi, err := os.Stat(filePath)
+	if err != nil || fi.IsDir() {
+		return "", http.StatusNotFound
+	}
+	return filePath, http.StatusOK
+}
+
+// isBelow reports whether path is root itself or located below root. Both must be clean, absolute paths.
+func isBelow(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
 // JSONResponse represents the type used for sending JSON data in Go.
 type JSONResponse struct {
 	Error   bool        `json:"error"`
@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -357,6 +358,61 @@ func TestDownloadStaticFile(t *testing.T) {
 	}
 }
 
+func TestDownloadStaticFileOutsidePath(t *testing.T) {
+	base := t.TempDir()
+	path := filepath.Join(base, "public")
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(base, "secret.txt"), filepath.Join(path, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file   string
+		status int
+	}{
+		{file: "a.txt", status: http.StatusOK},
+		{file: "../secret.txt", status: http.StatusForbidden},
+		{file: "link.txt", status: http.StatusForbidden},
+		{file: "missing.txt", status: http.StatusNotFound},
+		{file: ".", status: http.StatusNotFound},
+	}
+
+	var tools Tools
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		tools.DownloadStaticFile(rr, httptest.NewRequest("GET", "/", nil), path, test.file, "download")
+		if rr.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d\n", test.file, test.status, rr.Code)
+		}
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{filename: "kitten.png", want: `attachment; filename="kitten.png"`},
+		{filename: `say "hi".txt`, want: `attachment; filename="say \"hi\".txt"`},
+		{filename: "Grüße.txt", want: `attachment; filename="Gr__e.txt"; filename*=UTF-8''Gr%C3%BC%C3%9Fe.txt`},
+	}
+
+	for _, test := range tests {
+		got := contentDisposition(test.filename)
+		if got != test.want {
+			t.Errorf("%s: expected %s, got %s\n", test.filename, test.want, got)
+		}
+	}
+}
+
 var jsonTests = []struct {
 	name          string
 	json          string
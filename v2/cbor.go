@@ -0,0 +1,328 @@
+package toolkit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// cborCodec encodes application/cbor (RFC 8949). Tags are ignored when decoding; the tagged value is
+// used as it is.
+type cborCodec struct{}
+
+func (cborCodec) MediaTypes() []string { return []string{"application/cbor"} }
+
+func (cborCodec) Encode(w io.Writer, v interface{}) error {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := encodeCBOR(bw, generic); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (cborCodec) Decode(r io.Reader, v interface{}) error {
+	br := bufio.NewReader(r)
+	generic, err := decodeCBOR(br, 0)
+	if err != nil {
+		return err
+	}
+	if generic == cborBreak {
+		return errors.New("cbor: unexpected break")
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		return errors.New("cbor: unexpected data after top-level value")
+	}
+	return fromGeneric(generic, v)
+}
+
+// The major types of CBOR.
+const (
+	cborUint byte = iota
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// cborBreakCode is the type of cborBreak, which decodeCBOR returns for the stop code of
+// indefinite-length items.
+type cborBreakCode struct{}
+
+var cborBreak interface{} = cborBreakCode{}
+
+// encodeCBOR writes a value produced by toGeneric.
+func encodeCBOR(w *bufio.Writer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		return w.WriteByte(0xf6)
+
+	case bool:
+		if v {
+			return w.WriteByte(0xf5)
+		}
+		return w.WriteByte(0xf4)
+
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			if i < 0 {
+				return writeCBORHead(w, cborNegInt, uint64(-1-i))
+			}
+			return writeCBORHead(w, cborUint, uint64(i))
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return writeCBORHead(w, cborUint, u)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		return writeUint(w, cborSimple<<5|27, math.Float64bits(f), 8)
+
+	case string:
+		if err := writeCBORHead(w, cborText, uint64(len(v))); err != nil {
+			return err
+		}
+		_, err := w.WriteString(v)
+		return err
+
+	case []interface{}:
+		if err := writeCBORHead(w, cborArray, uint64(len(v))); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := encodeCBOR(w, item); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case map[string]interface{}:
+		if err := writeCBORHead(w, cborMap, uint64(len(v))); err != nil {
+			return err
+		}
+		for _, k := range sortedKeys(v) {
+			if err := encodeCBOR(w, k); err != nil {
+				return err
+			}
+			if err := encodeCBOR(w, v[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("cbor: unsupported type %T", v)
+}
+
+// writeCBORHead writes the initial byte of major type major with the argument n in the shortest form.
+func writeCBORHead(w *bufio.Writer, major byte, n uint64) error {
+	major <<= 5
+	switch {
+	case n < 24:
+		return w.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		return writeUint(w, major|24, n, 1)
+	case n <= math.MaxUint16:
+		return writeUint(w, major|25, n, 2)
+	case n <= math.MaxUint32:
+		return writeUint(w, major|26, n, 4)
+	}
+	return writeUint(w, major|27, n, 8)
+}
+
+// decodeCBOR reads a data item. Integers become int64 or uint64, floats float64, byte strings []byte
+// and maps map[string]interface{}.
+func decodeCBOR(r *bufio.Reader, depth int) (interface{}, error) {
+	if depth > maxDecodeDepth {
+		return nil, errDecodeDepth
+	}
+
+	initial, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	major, info := initial>>5, initial&0x1f
+
+	if major == cborSimple {
+		return decodeCBORSimple(r, info)
+	}
+
+	indefinite := info == 31
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		if n, err = readUint(r, 1<<(info-24)); err != nil {
+			return nil, err
+		}
+	case indefinite && major >= cborBytes && major <= cborMap:
+	default:
+		return nil, fmt.Errorf("cbor: invalid additional information %d", info)
+	}
+
+	switch major {
+	case cborUint:
+		if n <= math.MaxInt64 {
+			return int64(n), nil
+		}
+		return n, nil
+
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return nil, errors.New("cbor: negative integer out of range")
+		}
+		return -1 - int64(n), nil
+
+	case cborBytes, cborText:
+		var b []byte
+		if indefinite {
+			b, err = readCBORChunks(r, major, depth)
+		} else {
+			b, err = readBytes(r, n)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(b), nil
+		}
+		return b, nil
+
+	case cborArray:
+		list := make([]interface{}, 0, capHint(n))
+		for i := uint64(0); indefinite || i < n; i++ {
+			item, err := decodeCBOR(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if item == cborBreak {
+				if !indefinite {
+					return nil, errors.New("cbor: unexpected break")
+				}
+				break
+			}
+			list = append(list, item)
+		}
+		return list, nil
+
+	case cborMap:
+		m := make(map[string]interface{}, capHint(n))
+		for i := uint64(0); indefinite || i < n; i++ {
+			key, err := decodeCBOR(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if key == cborBreak {
+				if !indefinite {
+					return nil, errors.New("cbor: unexpected break")
+				}
+				break
+			}
+			k, err := mapKey(key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeCBOR(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if value == cborBreak {
+				return nil, errors.New("cbor: unexpected break")
+			}
+			m[k] = value
+		}
+		return m, nil
+	}
+
+	// cborTag: decode the tagged item.
+	return decodeCBOR(r, depth+1)
+}
+
+// readCBORChunks reads the definite-length chunks of an indefinite-length byte or text string.
+func readCBORChunks(r *bufio.Reader, major byte, depth int) ([]byte, error) {
+	var buf []byte
+	for {
+		chunk, err := decodeCBOR(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		switch c := chunk.(type) {
+		case []byte:
+			if major != cborBytes {
+				return nil, errors.New("cbor: invalid chunk in text string")
+			}
+			buf = append(buf, c...)
+		case string:
+			if major != cborText {
+				return nil, errors.New("cbor: invalid chunk in byte string")
+			}
+			buf = append(buf, c...)
+		default:
+			if chunk == cborBreak {
+				return buf, nil
+			}
+			return nil, errors.New("cbor: invalid chunk in indefinite-length string")
+		}
+	}
+}
+
+// decodeCBORSimple reads a simple value or float of major type 7.
+func decodeCBORSimple(r *bufio.Reader, info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		// null and undefined
+		return nil, nil
+	case 25:
+		u, err := readUint(r, 2)
+		return float16ToFloat64(uint16(u)), err
+	case 26:
+		u, err := readUint(r, 4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 27:
+		u, err := readUint(r, 8)
+		return math.Float64frombits(u), err
+	case 31:
+		return cborBreak, nil
+	}
+	return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+}
+
+// float16ToFloat64 converts an IEEE 754 half-precision float.
+func float16ToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
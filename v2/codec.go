@@ -0,0 +1,358 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Codec encodes and decodes bodies of a media type.
+type Codec interface {
+	// MediaTypes returns the media types the codec handles. The first one is used as Content-Type.
+	MediaTypes() []string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// The built-in codecs. YAMLCodec, MessagePackCodec and CBORCodec convert values via JSON, so they use
+// the json struct tags and []byte values are encoded as base64 strings. XMLCodec uses encoding/xml,
+// which can't encode maps. YAMLCodec implements the subset of YAML that JSON values need; it is not a
+// general YAML parser. Only JSON and XML are used by default, the others must be registered in
+// Tools.Codecs; a full implementation like gopkg.in/yaml.v3 can be registered by implementing Codec.
+var (
+	JSONCodec        Codec = jsonCodec{}
+	XMLCodec         Codec = xmlCodec{}
+	YAMLCodec        Codec = yamlCodec{}
+	MessagePackCodec Codec = msgpackCodec{}
+	CBORCodec        Codec = cborCodec{}
+)
+
+// defaultCodecs is used by Tools without Codecs.
+var defaultCodecs = NewCodecRegistry(JSONCodec, XMLCodec)
+
+// CodecRegistry holds the codecs WriteResponse and ReadBody choose from. The order of registration is the
+// order of preference if a client accepts several media types equally.
+type CodecRegistry struct {
+	mu     sync.RWMutex
+	codecs []Codec
+}
+
+// NewCodecRegistry creates a registry of codecs.
+func NewCodecRegistry(codecs ...Codec) *CodecRegistry {
+	c := &CodecRegistry{}
+	for _, codec := range codecs {
+		c.Register(codec)
+	}
+	return c
+}
+
+// Register adds codec to the registry. It replaces a codec with the same primary media type.
+func (c *CodecRegistry) Register(codec Codec) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, existing := range c.codecs {
+		if existing.MediaTypes()[0] == codec.MediaTypes()[0] {
+			c.codecs[i] = codec
+			return
+		}
+	}
+	c.codecs = append(c.codecs, codec)
+}
+
+// MediaTypes returns the primary media types of all codecs.
+func (c *CodecRegistry) MediaTypes() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	types := make([]string, len(c.codecs))
+	for i, codec := range c.codecs {
+		types[i] = codec.MediaTypes()[0]
+	}
+	return types
+}
+
+// Negotiate returns the codec that fits the Accept header accept best. An empty header accepts any
+// codec. The quality of the most specific matching range counts; ties are broken by the more specific
+// range, then by the order of the header and last by the order of registration.
+func (c *CodecRegistry) Negotiate(accept string) (Codec, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.codecs) == 0 {
+		return nil, false
+	}
+	if strings.TrimSpace(accept) == "" {
+		return c.codecs[0], true
+	}
+
+	ranges := parseQualityList(accept)
+	var (
+		best                  Codec
+		bestQ                 float64
+		bestSpecific, bestPos int
+	)
+	for _, codec := range c.codecs {
+		q, specific, pos := 0.0, 0, 0
+		for _, mediaType := range codec.MediaTypes() {
+			mq, ms, mp := matchMediaRange(ranges, mediaType)
+			if ms > specific || (ms == specific && mq > q) {
+				q, specific, pos = mq, ms, mp
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if best == nil || q > bestQ || (q == bestQ && (specific > bestSpecific || (specific == bestSpecific && pos < bestPos))) {
+			best, bestQ, bestSpecific, bestPos = codec, q, specific, pos
+		}
+	}
+	return best, best != nil
+}
+
+// matchMediaRange returns the quality the most specific range of ranges gives mediaType, how specific
+// the range is (3 for type/subtype, 2 for type/*, 1 for */*, 0 for none) and its position in ranges.
+func matchMediaRange(ranges []qualityValue, mediaType string) (q float64, specific, pos int) {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	for i, r := range ranges {
+		s := 0
+		switch r.value {
+		case mediaType:
+			s = 3
+		case typ + "/*":
+			s = 2
+		case "*/*":
+			s = 1
+		}
+		if s > specific {
+			q, specific, pos = r.q, s, i
+		}
+	}
+	return q, specific, pos
+}
+
+// Lookup returns the codec for the Content-Type contentType. Parameters are ignored. Types with a +json
+// or +xml suffix, e.g. application/merge-patch+json, fall back to the JSON or XML codec.
+func (c *CodecRegistry) Lookup(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	candidates := []string{mediaType}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		switch mediaType[i+1:] {
+		case "json":
+			candidates = append(candidates, "application/json")
+		case "xml":
+			candidates = append(candidates, "application/xml")
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, candidate := range candidates {
+		for _, codec := range c.codecs {
+			for _, mt := range codec.MediaTypes() {
+				if mt == candidate {
+					return codec, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// NotAcceptableError is returned by WriteResponse if no codec produces a media type the client accepts.
+type NotAcceptableError struct {
+	Accept    string
+	Available []string
+}
+
+// Error implements the error interface.
+func (e *NotAcceptableError) Error() string {
+	return fmt.Sprintf("none of the media types %s is acceptable", strings.Join(e.Available, ", "))
+}
+
+// StatusCode returns http.StatusNotAcceptable.
+func (e *NotAcceptableError) StatusCode() int { return http.StatusNotAcceptable }
+
+// UnsupportedMediaTypeError is returned by ReadBody if there is no codec for the Content-Type of the body.
+type UnsupportedMediaTypeError struct {
+	ContentType string
+	Supported   []string
+}
+
+// Error implements the error interface.
+func (e *UnsupportedMediaTypeError) Error() string {
+	return fmt.Sprintf("unsupported content type %q, use one of %s", e.ContentType, strings.Join(e.Supported, ", "))
+}
+
+// StatusCode returns http.StatusUnsupportedMediaType.
+func (e *UnsupportedMediaTypeError) StatusCode() int { return http.StatusUnsupportedMediaType }
+
+// BodyDecodeError is returned by ReadBody if a codec other than JSONCodec fails to decode the body.
+type BodyDecodeError struct {
+	MediaType string
+	Err       error
+}
+
+// Error implements the error interface.
+func (e *BodyDecodeError) Error() string {
+	return fmt.Sprintf("body contains invalid %s: %v", e.MediaType, e.Err)
+}
+
+// Unwrap returns the error of the codec.
+func (e *BodyDecodeError) Unwrap() error { return e.Err }
+
+// StatusCode returns http.StatusBadRequest.
+func (e *BodyDecodeError) StatusCode() int { return http.StatusBadRequest }
+
+// codecs returns the registry of t or the registry of the built-in codecs.
+func (t *Tools) codecs() *CodecRegistry {
+	if t.Codecs != nil {
+		return t.Codecs
+	}
+	return defaultCodecs
+}
+
+// WriteResponse writes data with the codec that fits the Accept header of r best, see
+// CodecRegistry.Negotiate. JSON is written by WriteJSON. If the client accepts none of the media types,
+// nothing is written and a *NotAcceptableError is returned, which the caller answers with 406 Not
+// Acceptable, e.g. by ErrorJSON. The response is compressed if Compression is set.
+func (t *Tools) WriteResponse(w http.ResponseWriter, r *http.Request, status int, data interface{}, headers ...http.Header) error {
+	registry := t.codecs()
+	w.Header().Add("Vary", "Accept")
+
+	codec, ok := registry.Negotiate(r.Header.Get("Accept"))
+	if !ok {
+		return &NotAcceptableError{Accept: r.Header.Get("Accept"), Available: registry.MediaTypes()}
+	}
+
+	if _, isJSON := codec.(jsonCodec); isJSON {
+		return t.writeJSON(w, r, status, data, headers...)
+	}
+
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, data); err != nil {
+		return err
+	}
+	if len(headers) > 0 {
+		for k, v := range headers[0] {
+			w.Header()[k] = v
+		}
+	}
+
+	w.Header().Set("Content-Type", codec.MediaTypes()[0])
+	return t.writeBody(w, r, status, buf.Bytes())
+}
+
+// ReadBody decodes the body of r into data with the codec for its Content-Type. A body without
+// Content-Type is read as JSON. JSON is read by ReadJSON; for the other codecs the body is decompressed
+// according to its Content-Encoding, MaxJSONSize limits it as well, decoding errors are returned as
+// *BodyDecodeError and data is validated if ValidateJSON is set. Like WriteResponse, ReadBody doesn't
+// write errors; if there is no codec for the Content-Type, an *UnsupportedMediaTypeError is returned,
+// which the caller answers with 415 Unsupported Media Type.
+func (t *Tools) ReadBody(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	registry := t.codecs()
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	codec, ok := registry.Lookup(contentType)
+	if !ok {
+		return &UnsupportedMediaTypeError{ContentType: contentType, Supported: registry.MediaTypes()}
+	}
+	if _, isJSON := codec.(jsonCodec); isJSON {
+		return t.ReadJSON(w, r, data)
+	}
+
+	if err := t.limitBody(w, r, t.maxJSONSize()); err != nil {
+		return err
+	}
+	if err := codec.Decode(r.Body, data); err != nil {
+		var maxBytesError *http.MaxBytesError
+		var tooLarge *JSONTooLargeError
+		var encodingErr *ContentEncodingError
+		switch {
+		case errors.As(err, &maxBytesError):
+			return &JSONTooLargeError{Limit: maxBytesError.Limit}
+		case errors.As(err, &tooLarge):
+			return tooLarge
+		case errors.As(err, &encodingErr):
+			return encodingErr
+		}
+		return &BodyDecodeError{MediaType: codec.MediaTypes()[0], Err: err}
+	}
+
+	if t.ValidateJSON {
+		return t.Validate(data)
+	}
+	return nil
+}
+
+// jsonCodec encodes application/json with encoding/json.
+type jsonCodec struct{}
+
+func (jsonCodec) MediaTypes() []string { return []string{"application/json"} }
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// xmlCodec encodes application/xml with encoding/xml.
+type xmlCodec struct{}
+
+func (xmlCodec) MediaTypes() []string { return []string{"application/xml", "text/xml"} }
+
+func (xmlCodec) Encode(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func (xmlCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// toGeneric converts v into maps, slices, strings, json.Numbers, bools and nils by a round trip through
+// JSON, so that the codecs built on it honour json tags and json.Marshaler.
+func toGeneric(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	err = dec.Decode(&generic)
+	return generic, err
+}
+
+// fromGeneric stores a value produced by one of the decoders in v.
+func fromGeneric(generic interface{}, v interface{}) error {
+	data, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
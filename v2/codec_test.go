@@ -0,0 +1,346 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type codecItem struct {
+	Name    string            `json:"name" xml:"name" validate:"required"`
+	Count   int               `json:"count" xml:"count"`
+	Price   float64           `json:"price" xml:"price"`
+	Tags    []string          `json:"tags" xml:"tags"`
+	Active  bool              `json:"active" xml:"active"`
+	Note    *string           `json:"note" xml:"note"`
+	Big     uint64            `json:"big" xml:"big"`
+	Neg     int64             `json:"neg" xml:"neg"`
+	Labels  map[string]string `json:"labels,omitempty" xml:"-"`
+	Comment string            `json:"comment" xml:"comment"`
+}
+
+func testCodecItem() codecItem {
+	return codecItem{
+		Name:    "Widget",
+		Count:   3,
+		Price:   9.95,
+		Tags:    []string{"a", "b: c", "true", "1"},
+		Active:  true,
+		Big:     1 << 63,
+		Neg:     -70000,
+		Comment: "line 1\nline 2 # not a comment",
+	}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec, XMLCodec, YAMLCodec, MessagePackCodec, CBORCodec} {
+		item := testCodecItem()
+		if codec != XMLCodec {
+			item.Labels = map[string]string{"env": "prod", "": "empty key"}
+		}
+
+		var buf bytes.Buffer
+		if err := codec.Encode(&buf, item); err != nil {
+			t.Errorf("%s: unexpected error encoding: %v\n", codec.MediaTypes()[0], err)
+			continue
+		}
+
+		var got codecItem
+		if err := codec.Decode(&buf, &got); err != nil {
+			t.Errorf("%s: unexpected error decoding: %v\n", codec.MediaTypes()[0], err)
+			continue
+		}
+		if !reflect.DeepEqual(got, item) {
+			t.Errorf("%s: expected %+v, got %+v\n", codec.MediaTypes()[0], item, got)
+		}
+	}
+}
+
+func TestMessagePackEncoding(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{value: nil, want: "c0"},
+		{value: true, want: "c3"},
+		{value: 1, want: "01"},
+		{value: -1, want: "ff"},
+		{value: 200, want: "ccc8"},
+		{value: -200, want: "d1ff38"},
+		{value: 1.5, want: "cb3ff8000000000000"},
+		{value: "abc", want: "a3616263"},
+		{value: []int{1, 2}, want: "920102"},
+		{value: map[string]int{"b": 2, "a": 1}, want: "82a16101a16202"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := MessagePackCodec.Encode(&buf, test.value); err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(buf.Bytes()); got != test.want {
+			t.Errorf("%v: expected %s, got %s\n", test.value, test.want, got)
+		}
+	}
+}
+
+func TestCBOREncoding(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{value: nil, want: "f6"},
+		{value: false, want: "f4"},
+		{value: 10, want: "0a"},
+		{value: 100, want: "1864"},
+		{value: -1000, want: "3903e7"},
+		{value: 1.1, want: "fb3ff199999999999a"},
+		{value: "IETF", want: "6449455446"},
+		{value: []int{1, 2, 3}, want: "83010203"},
+		{value: map[string]int{"b": 2, "a": 1}, want: "a2616101616202"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := CBORCodec.Encode(&buf, test.value); err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(buf.Bytes()); got != test.want {
+			t.Errorf("%v: expected %s, got %s\n", test.value, test.want, got)
+		}
+	}
+}
+
+func TestCBORDecoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "half float", input: "f93e00", want: 1.5},
+		{name: "indefinite array", input: "9f0102ff", want: []interface{}{float64(1), float64(2)}},
+		{name: "indefinite text", input: "7f626162626364ff", want: "abcd"},
+		{name: "tag", input: "c11a514b67b0", want: float64(1363896240)},
+		{name: "indefinite map", input: "bf6161f5ff", want: map[string]interface{}{"a": true}},
+		{name: "truncated", input: "830102", wantErr: true},
+		{name: "trailing data", input: "0101", wantErr: true},
+		{name: "stray break", input: "ff", wantErr: true},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.input)
+		var got interface{}
+		err := CBORCodec.Decode(bytes.NewReader(data), &got)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error\n", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v\n", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected %v, got %v\n", test.name, test.want, got)
+		}
+	}
+}
+
+func TestCodecRegistryNegotiate(t *testing.T) {
+	registry := NewCodecRegistry(JSONCodec, XMLCodec, YAMLCodec, MessagePackCodec, CBORCodec)
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{accept: "", want: "application/json"},
+		{accept: "*/*", want: "application/json"},
+		{accept: "application/xml", want: "application/xml"},
+		{accept: "text/xml", want: "application/xml"},
+		{accept: "application/yaml, application/json", want: "application/yaml"},
+		{accept: "application/json;q=0.5, application/cbor", want: "application/cbor"},
+		{accept: "application/x-msgpack", want: "application/msgpack"},
+		{accept: "text/*", want: "application/xml"},
+		{accept: "application/json;q=0, */*", want: "application/xml"},
+		{accept: "text/html, */*;q=0.1", want: "application/json"},
+		{accept: "text/html", want: ""},
+	}
+
+	for _, test := range tests {
+		codec, ok := registry.Negotiate(test.accept)
+		got := ""
+		if ok {
+			got = codec.MediaTypes()[0]
+		}
+		if got != test.want {
+			t.Errorf("%q: expected %q, got %q\n", test.accept, test.want, got)
+		}
+	}
+}
+
+func TestCodecRegistryLookup(t *testing.T) {
+	registry := NewCodecRegistry(JSONCodec, XMLCodec, YAMLCodec)
+
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{contentType: "application/json; charset=utf-8", want: "application/json"},
+		{contentType: "application/merge-patch+json", want: "application/json"},
+		{contentType: "application/atom+xml", want: "application/xml"},
+		{contentType: "application/x-yaml", want: "application/yaml"},
+		{contentType: "application/cbor", want: ""},
+		{contentType: "not a media type", want: ""},
+	}
+
+	for _, test := range tests {
+		codec, ok := registry.Lookup(test.contentType)
+		got := ""
+		if ok {
+			got = codec.MediaTypes()[0]
+		}
+		if got != test.want {
+			t.Errorf("%q: expected %q, got %q\n", test.contentType, test.want, got)
+		}
+	}
+}
+
+// allCodecs registers the built-in codecs, including those that are not used by default.
+var allCodecs = NewCodecRegistry(JSONCodec, XMLCodec, YAMLCodec, MessagePackCodec, CBORCodec)
+
+func TestWriteResponse(t *testing.T) {
+	tool := Tools{Codecs: allCodecs}
+
+	tests := []struct {
+		name        string
+		accept      string
+		status      int
+		contentType string
+	}{
+		{name: "json", accept: "application/json", status: http.StatusOK, contentType: "application/json"},
+		{name: "yaml", accept: "application/yaml", status: http.StatusOK, contentType: "application/yaml"},
+		{name: "cbor", accept: "application/cbor", status: http.StatusOK, contentType: "application/cbor"},
+		{name: "not acceptable", accept: "image/png", status: http.StatusNotAcceptable},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", test.accept)
+		rr := httptest.NewRecorder()
+
+		err := tool.WriteResponse(rr, req, http.StatusOK, testCodecItem(), http.Header{"X-Test": []string{"yes"}})
+		if test.status == http.StatusNotAcceptable {
+			var notAcceptable *NotAcceptableError
+			if !errors.As(err, &notAcceptable) || notAcceptable.StatusCode() != test.status {
+				t.Errorf("%s: expected *NotAcceptableError, got %v\n", test.name, err)
+			}
+			// The error is left to the caller.
+			if rr.Body.Len() != 0 {
+				t.Errorf("%s: expected nothing to be written, got %q\n", test.name, rr.Body.String())
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %v\n", test.name, err)
+		} else if rr.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, test.status, rr.Code)
+		}
+		if got := rr.Header().Get("Content-Type"); got != test.contentType {
+			t.Errorf("%s: expected content type %s, got %s\n", test.name, test.contentType, got)
+		}
+		if got := rr.Header().Get("Vary"); got != "Accept" {
+			t.Errorf("%s: expected Vary: Accept, got %q\n", test.name, got)
+		}
+		if test.status == http.StatusOK && rr.Header().Get("X-Test") != "yes" {
+			t.Errorf("%s: header not set\n", test.name)
+		}
+	}
+}
+
+func TestDefaultCodecs(t *testing.T) {
+	var tool Tools
+	for _, mediaType := range []string{"application/yaml", "application/msgpack", "application/cbor"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", mediaType)
+		var notAcceptable *NotAcceptableError
+		if err := tool.WriteResponse(httptest.NewRecorder(), req, http.StatusOK, testCodecItem()); !errors.As(err, &notAcceptable) {
+			t.Errorf("%s: expected *NotAcceptableError without registration, got %v\n", mediaType, err)
+		}
+	}
+	if got := defaultCodecs.MediaTypes(); len(got) == 0 || got[0] != "application/json" {
+		t.Errorf("expected JSON to be preferred, got %v\n", got)
+	}
+}
+
+func TestWriteResponseNotAcceptableProblem(t *testing.T) {
+	tool := Tools{Compression: NewCompression(CompressionOptions{})}
+	handler := tool.ProblemHandler(func(w http.ResponseWriter, r *http.Request) error {
+		return tool.WriteResponse(w, r, http.StatusOK, testCodecItem())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set("Accept", "image/png")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var p Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+		t.Fatalf("expected a single problem, got %q: %v", rr.Body.String(), err)
+	}
+	if rr.Code != http.StatusNotAcceptable || p.Status != http.StatusNotAcceptable || rr.Header().Get("Content-Type") != ProblemContentType {
+		t.Errorf("expected 406 problem, got %d %s %+v\n", rr.Code, rr.Header().Get("Content-Type"), p)
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		validate    bool
+		maxSize     int
+		wantStatus  int
+	}{
+		{name: "yaml", contentType: "application/yaml", body: "name: Widget\ncount: 3\n"},
+		{name: "json", contentType: "application/json", body: `{"name": "Widget", "count": 3}`},
+		{name: "default json", body: `{"name": "Widget", "count": 3}`},
+		{name: "xml", contentType: "text/xml", body: "<codecItem><name>Widget</name><count>3</count></codecItem>"},
+		{name: "unsupported", contentType: "text/csv", body: "name,count", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "bad yaml", contentType: "application/yaml", body: "name: [Widget", wantStatus: http.StatusBadRequest},
+		{name: "yaml wrong type", contentType: "application/yaml", body: "count: many", wantStatus: http.StatusBadRequest},
+		{name: "too large", contentType: "application/yaml", body: "name: " + strings.Repeat("x", 100), maxSize: 50, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "invalid", contentType: "application/yaml", body: "count: 3", validate: true, wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, test := range tests {
+		tool := Tools{ValidateJSON: test.validate, MaxJSONSize: test.maxSize, Codecs: allCodecs}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+		if test.contentType != "" {
+			req.Header.Set("Content-Type", test.contentType)
+		}
+
+		var item codecItem
+		err := tool.ReadBody(httptest.NewRecorder(), req, &item)
+		if test.wantStatus != 0 {
+			var statusErr StatusCoder
+			if !errors.As(err, &statusErr) || statusErr.StatusCode() != test.wantStatus {
+				t.Errorf("%s: expected error with status %d, got %v\n", test.name, test.wantStatus, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v\n", test.name, err)
+			continue
+		}
+		if item.Name != "Widget" || item.Count != 3 {
+			t.Errorf("%s: unexpected result %+v\n", test.name, item)
+		}
+	}
+}
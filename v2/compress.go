@@ -0,0 +1,314 @@
+package toolkit
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// CompressWriter is a compressing writer that can be reused by resetting it to another destination.
+// *gzip.Writer, *flate.Writer and *brotli.Writer implement it.
+type CompressWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// CompressionOptions configures a Compression.
+type CompressionOptions struct {
+	// MinSize is the minimum size of a response body to be compressed. The default is 1024 bytes.
+	MinSize int
+	// Level is the compression level passed to the writer factories; zero means the default level of
+	// the coding.
+	Level int
+	// Types is the allowlist of media types to compress, e.g. "application/json". Without a list, text
+	// types, JSON, XML and a few others are compressed.
+	Types []string
+}
+
+// Compression compresses responses with brotli, gzip, deflate or any coding registered with Register,
+// according to the Accept-Encoding header of the request. Use Middleware to compress the responses of a handler or
+// assign it to Tools.Compression. A Compression is safe for concurrent use and is meant to be shared.
+type Compression struct {
+	opts CompressionOptions
+
+	mu      sync.RWMutex
+	codings []string
+	pools   map[string]*sync.Pool
+}
+
+// NewCompression creates a Compression supporting brotli (br), gzip and deflate. Clients accepting
+// several of them equally get brotli, which compresses best.
+func NewCompression(opts CompressionOptions) *Compression {
+	if opts.MinSize <= 0 {
+		opts.MinSize = minGzipSize
+	}
+	c := &Compression{opts: opts, pools: make(map[string]*sync.Pool)}
+
+	c.Register("deflate", func(w io.Writer, level int) CompressWriter {
+		if level == 0 {
+			level = flate.DefaultCompression
+		}
+		fw, err := flate.NewWriter(w, level)
+		if err != nil {
+			fw, _ = flate.NewWriter(w, flate.DefaultCompression)
+		}
+		return fw
+	})
+	c.Register("gzip", func(w io.Writer, level int) CompressWriter {
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		gw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			gw = gzip.NewWriter(w)
+		}
+		return gw
+	})
+	c.Register("br", func(w io.Writer, level int) CompressWriter {
+		if level <= 0 || level > brotli.BestCompression {
+			level = brotli.DefaultCompression
+		}
+		return brotli.NewWriterLevel(w, level)
+	})
+	return c
+}
+
+// Register adds the content coding coding, e.g. "zstd", whose writers are created by newWriter with the
+// configured level. Codings registered later are preferred if the client accepts several equally, so a
+// registered coding takes precedence over brotli, gzip and deflate. Registering a coding again replaces
+// its writers, e.g. to configure brotli differently:
+//
+//	c.Register("br", func(w io.Writer, level int) toolkit.CompressWriter {
+//		return brotli.NewWriterOptions(w, brotli.WriterOptions{Quality: level, LGWin: 18})
+//	})
+func (c *Compression) Register(coding string, newWriter func(w io.Writer, level int) CompressWriter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	level := c.opts.Level
+	pool := &sync.Pool{New: func() interface{} {
+		return newWriter(io.Discard, level)
+	}}
+	if _, exists := c.pools[coding]; !exists {
+		c.codings = append([]string{coding}, c.codings...)
+	}
+	c.pools[coding] = pool
+}
+
+// Middleware compresses the responses of next.
+func (c *Compression) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := c.newWriter(w, r)
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// writeBody writes status and body as response to r, compressed if Compression is set. Without the
+// request, i.e. if r is nil, the body is written as it is.
+func (t *Tools) writeBody(w http.ResponseWriter, r *http.Request, status int, body []byte) error {
+	if t.Compression == nil || r == nil {
+		w.WriteHeader(status)
+		_, err := w.Write(body)
+		return err
+	}
+
+	cw := t.Compression.newWriter(w, r)
+	cw.WriteHeader(status)
+	if _, err := cw.Write(body); err != nil {
+		_ = cw.Close()
+		return err
+	}
+	return cw.Close()
+}
+
+// negotiate returns the coding with the highest weight in acceptEncoding, or "" if the response must not
+// be compressed.
+func (c *Compression) negotiate(acceptEncoding string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	best, bestQ := "", 0.0
+	for _, coding := range c.codings {
+		if q := encodingQuality(acceptEncoding, coding); q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether responses of contentType are compressed.
+func (c *Compression) compressible(contentType string) bool {
+	if len(c.opts.Types) == 0 {
+		return isCompressible(contentType)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range c.opts.Types {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// newWriter wraps w in a writer that compresses the response to r if possible.
+func (c *Compression) newWriter(w http.ResponseWriter, r *http.Request) *compressResponseWriter {
+	cw := &compressResponseWriter{ResponseWriter: w, c: c, status: http.StatusOK}
+	if r.Method != http.MethodHead {
+		cw.coding = c.negotiate(r.Header.Get("Accept-Encoding"))
+	}
+	return cw
+}
+
+// compressResponseWriter buffers the start of a response until it knows whether to compress it: the
+// response must be at least MinSize bytes large (or flushed before), of a compressible type and not
+// already encoded.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	c      *Compression
+	coding string
+
+	status        int
+	headerWritten bool
+	decided       bool
+	buf           []byte
+	enc           CompressWriter
+}
+
+// WriteHeader records the status. The header is sent once it is decided whether to compress.
+func (cw *compressResponseWriter) WriteHeader(status int) {
+	if cw.headerWritten {
+		return
+	}
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+	cw.headerWritten = true
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		_ = cw.decide(false)
+	}
+}
+
+// Write buffers p until the decision to compress is made and writes it afterwards.
+func (cw *compressResponseWriter) Write(p []byte) (int, error) {
+	cw.headerWritten = true
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.c.opts.MinSize {
+			return len(p), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide sends the header and the buffered data, compressed if large is set and the response qualifies.
+func (cw *compressResponseWriter) decide(large bool) error {
+	cw.decided = true
+	h := cw.Header()
+
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	eligible := cw.status != http.StatusNoContent && cw.status != http.StatusNotModified &&
+		cw.status != http.StatusPartialContent && h.Get("Content-Encoding") == "" &&
+		h.Get("Content-Range") == "" && cw.c.compressible(h.Get("Content-Type"))
+	if eligible {
+		h.Add("Vary", "Accept-Encoding")
+	}
+
+	if eligible && large && cw.coding != "" {
+		h.Set("Content-Encoding", cw.coding)
+		h.Del("Content-Length")
+		// Ranges would refer to the compressed representation.
+		h.Del("Accept-Ranges")
+		cw.ResponseWriter.WriteHeader(cw.status)
+
+		cw.c.mu.RLock()
+		cw.enc = cw.c.pools[cw.coding].Get().(CompressWriter)
+		cw.c.mu.RUnlock()
+		cw.enc.Reset(cw.ResponseWriter)
+	} else {
+		if !large && len(cw.buf) > 0 && h.Get("Content-Length") == "" {
+			h.Set("Content-Length", strconv.Itoa(len(cw.buf)))
+		}
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Flush sends the data written so far. As a flush indicates a streamed response, it is compressed even
+// if it is smaller than MinSize.
+func (cw *compressResponseWriter) Flush() {
+	if !cw.decided {
+		cw.headerWritten = true
+		_ = cw.decide(true)
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close finishes the response and returns the compressing writer to its pool.
+func (cw *compressResponseWriter) Close() error {
+	if !cw.decided {
+		if !cw.headerWritten {
+			// Nothing has been written, leave the response to the server.
+			return nil
+		}
+		if err := cw.decide(false); err != nil {
+			return err
+		}
+	}
+	if cw.enc == nil {
+		return nil
+	}
+
+	err := cw.enc.Close()
+	cw.enc.Reset(io.Discard)
+	cw.c.mu.RLock()
+	cw.c.pools[cw.coding].Put(cw.enc)
+	cw.c.mu.RUnlock()
+	cw.enc = nil
+	return err
+}
@@ -0,0 +1,235 @@
+package toolkit
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// decodeBody returns the body of rr decoded according to its Content-Encoding.
+func decodeBody(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var r io.Reader = rr.Body
+	switch rr.Header().Get("Content-Encoding") {
+	case "br":
+		r = brotli.NewReader(rr.Body)
+	case "gzip", "zstd":
+		gr, err := gzip.NewReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gr
+	case "deflate":
+		r = flate.NewReader(rr.Body)
+	}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	large := `{"data": "` + strings.Repeat("compress me ", 200) + `"}`
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		encoded        string
+		body           string
+		status         int
+		opts           CompressionOptions
+		wantEncoding   string
+		wantVary       bool
+		wantLength     bool
+	}{
+		{name: "gzip", acceptEncoding: "gzip, deflate", contentType: "application/json", body: large, wantEncoding: "gzip", wantVary: true},
+		{name: "brotli", acceptEncoding: "gzip, deflate, br", contentType: "application/json", body: large, wantEncoding: "br", wantVary: true},
+		{name: "brotli level", acceptEncoding: "br", contentType: "application/json", body: large, opts: CompressionOptions{Level: gzip.BestCompression}, wantEncoding: "br", wantVary: true},
+		{name: "q-values", acceptEncoding: "gzip;q=0.5, deflate", contentType: "application/json", body: large, wantEncoding: "deflate", wantVary: true},
+		{name: "refused", acceptEncoding: "gzip;q=0", contentType: "application/json", body: large, wantVary: true},
+		{name: "no header", contentType: "application/json", body: large, wantVary: true},
+		{name: "small", acceptEncoding: "gzip", contentType: "application/json", body: `{"a": 1}`, wantVary: true, wantLength: true},
+		{name: "incompressible", acceptEncoding: "gzip", contentType: "image/png", body: large},
+		{name: "sniffed", acceptEncoding: "gzip", body: strings.Repeat("plain text ", 200), wantEncoding: "gzip", wantVary: true},
+		{name: "already encoded", acceptEncoding: "gzip", contentType: "application/json", encoded: "br", body: large},
+		{name: "not modified", acceptEncoding: "gzip", contentType: "application/json", status: http.StatusNotModified},
+		{name: "allowlist", acceptEncoding: "gzip", contentType: "application/json", body: large, opts: CompressionOptions{Types: []string{"text/csv"}}},
+		{name: "min size", acceptEncoding: "gzip", contentType: "application/json", body: `{"a": 1}`, opts: CompressionOptions{MinSize: 5}, wantEncoding: "gzip", wantVary: true},
+	}
+
+	for _, test := range tests {
+		c := NewCompression(test.opts)
+		handler := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.contentType != "" {
+				w.Header().Set("Content-Type", test.contentType)
+			}
+			if test.encoded != "" {
+				w.Header().Set("Content-Encoding", test.encoded)
+			}
+			if test.status != 0 {
+				w.WriteHeader(test.status)
+			}
+			// Write in pieces to cross the size threshold within a write.
+			half := len(test.body) / 2
+			_, _ = io.WriteString(w, test.body[:half])
+			_, _ = io.WriteString(w, test.body[half:])
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", test.acceptEncoding)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		wantStatus := http.StatusOK
+		if test.status != 0 {
+			wantStatus = test.status
+		}
+		if rr.Code != wantStatus {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, wantStatus, rr.Code)
+		}
+		if test.encoded != "" {
+			continue
+		}
+		if got := rr.Header().Get("Content-Encoding"); got != test.wantEncoding {
+			t.Errorf("%s: expected encoding %q, got %q\n", test.name, test.wantEncoding, got)
+		}
+		if got := rr.Header().Get("Vary") == "Accept-Encoding"; got != test.wantVary {
+			t.Errorf("%s: expected Vary %v, got %v\n", test.name, test.wantVary, got)
+		}
+		if got := rr.Header().Get("Content-Length") != ""; got != test.wantLength {
+			t.Errorf("%s: expected Content-Length %v, got %q\n", test.name, test.wantLength, rr.Header().Get("Content-Length"))
+		}
+		if got := decodeBody(t, rr); got != test.body {
+			t.Errorf("%s: body doesn't match, got %d bytes\n", test.name, len(got))
+		}
+	}
+}
+
+func TestCompressionRegister(t *testing.T) {
+	c := NewCompression(CompressionOptions{})
+	// A stand-in for zstd.
+	c.Register("zstd", func(w io.Writer, level int) CompressWriter {
+		return gzip.NewWriter(w)
+	})
+
+	handler := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, strings.Repeat("a", 2000))
+	}))
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip, deflate, br, zstd")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if got := rr.Header().Get("Content-Encoding"); got != "zstd" {
+			t.Errorf("expected zstd to be preferred, got %q\n", got)
+		}
+		if got := decodeBody(t, rr); got != strings.Repeat("a", 2000) {
+			t.Errorf("body doesn't match after %d requests\n", i+1)
+		}
+	}
+}
+
+func TestCompressionFlush(t *testing.T) {
+	c := NewCompression(CompressionOptions{})
+	handler := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"a":1}`)
+		w.(http.Flusher).Flush()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("expected a flushed response to be compressed, got %q\n", got)
+	}
+	if !rr.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+	if got := decodeBody(t, rr); got != `{"a":1}` {
+		t.Errorf("unexpected body %q\n", got)
+	}
+}
+
+func TestToolsCompression(t *testing.T) {
+	tool := Tools{Compression: NewCompression(CompressionOptions{MinSize: 10})}
+	data := map[string]string{"message": strings.Repeat("hello ", 50)}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	if err := tool.WriteResponse(rr, req, http.StatusCreated, data); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusCreated || rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("expected compressed 201 response, got %d %q\n", rr.Code, rr.Header().Get("Content-Encoding"))
+	}
+	if got := decodeBody(t, rr); !strings.Contains(got, "hello hello") {
+		t.Errorf("unexpected body %q\n", got)
+	}
+
+	rr = httptest.NewRecorder()
+	values := func(yield func(int) error) error {
+		for i := 0; i < 3; i++ {
+			if err := yield(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := StreamJSONArray(&tool, rr, req, http.StatusOK, values); err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeBody(t, rr); got != "[0,1,2]" {
+		t.Errorf("unexpected stream body %q\n", got)
+	}
+
+	writers := []struct {
+		name         string
+		write        func(w http.ResponseWriter) error
+		wantEncoding string
+	}{
+		{name: "WriteJSONFor", write: func(w http.ResponseWriter) error {
+			return tool.WriteJSONFor(w, req, http.StatusOK, data)
+		}, wantEncoding: "gzip"},
+		{name: "ErrorJSONFor", write: func(w http.ResponseWriter) error {
+			return tool.ErrorJSONFor(w, req, errors.New(data["message"]))
+		}, wantEncoding: "gzip"},
+		{name: "WriteJSONCached", write: func(w http.ResponseWriter) error {
+			return tool.WriteJSONCached(w, req, http.StatusOK, data, CacheOptions{})
+		}, wantEncoding: "gzip"},
+		{name: "WriteJSON", write: func(w http.ResponseWriter) error {
+			return tool.WriteJSON(w, http.StatusOK, data)
+		}},
+	}
+	for _, writer := range writers {
+		rr = httptest.NewRecorder()
+		if err := writer.write(rr); err != nil {
+			t.Fatal(err)
+		}
+		if got := rr.Header().Get("Content-Encoding"); got != writer.wantEncoding {
+			t.Errorf("%s: expected encoding %q, got %q\n", writer.name, writer.wantEncoding, got)
+		}
+		if got := decodeBody(t, rr); !strings.Contains(got, "hello hello") {
+			t.Errorf("%s: unexpected body %q\n", writer.name, got)
+		}
+	}
+}
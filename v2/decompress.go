@@ -0,0 +1,209 @@
+package toolkit
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Decompressor returns a reader that decompresses r. It is registered for a content coding in
+// Tools.Decompressors.
+type Decompressor func(r io.Reader) (io.Reader, error)
+
+// defaultDecompressors are the codings request bodies can always be encoded with.
+var defaultDecompressors = map[string]Decompressor{
+	"gzip":    gzipDecompressor,
+	"x-gzip":  gzipDecompressor,
+	"deflate": deflateDecompressor,
+	"br":      brotliDecompressor,
+}
+
+func gzipDecompressor(r io.Reader) (io.Reader, error) {
+	return gzip.NewReader(r)
+}
+
+func brotliDecompressor(r io.Reader) (io.Reader, error) {
+	return brotli.NewReader(r), nil
+}
+
+// deflateDecompressor reads the zlib format as specified for the deflate coding, and raw deflate data
+// as sent by some clients.
+func deflateDecompressor(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// UnsupportedEncodingError is returned if a request body has a Content-Encoding that can't be decoded.
+type UnsupportedEncodingError struct {
+	Encoding  string
+	Supported []string
+}
+
+// Error implements the error interface.
+func (e *UnsupportedEncodingError) Error() string {
+	return fmt.Sprintf("unsupported content encoding %q, use one of %s", e.Encoding, strings.Join(e.Supported, ", "))
+}
+
+// StatusCode returns http.StatusUnsupportedMediaType.
+func (e *UnsupportedEncodingError) StatusCode() int { return http.StatusUnsupportedMediaType }
+
+// ContentEncodingError is returned if a request body is not correctly encoded with its Content-Encoding.
+type ContentEncodingError struct {
+	Encoding string
+	Err      error
+}
+
+// Error implements the error interface.
+func (e *ContentEncodingError) Error() string {
+	return fmt.Sprintf("body is not correctly encoded with %s", e.Encoding)
+}
+
+// Unwrap returns the error of the decompressor.
+func (e *ContentEncodingError) Unwrap() error { return e.Err }
+
+// StatusCode returns http.StatusBadRequest.
+func (e *ContentEncodingError) StatusCode() int { return http.StatusBadRequest }
+
+// decompressor returns the Decompressor for coding.
+func (t *Tools) decompressor(coding string) (Decompressor, bool) {
+	if d, ok := t.Decompressors[coding]; ok {
+		return d, true
+	}
+	d, ok := defaultDecompressors[coding]
+	return d, ok
+}
+
+// supportedEncodings returns the codings request bodies may be encoded with.
+func (t *Tools) supportedEncodings() []string {
+	var codings []string
+	for coding := range defaultDecompressors {
+		codings = append(codings, coding)
+	}
+	for coding := range t.Decompressors {
+		if _, ok := defaultDecompressors[coding]; !ok {
+			codings = append(codings, coding)
+		}
+	}
+	sort.Strings(codings)
+	return codings
+}
+
+// limitBody limits the body of r to maxBytes and decodes it according to its Content-Encoding. Zero means
+// no limit. The decoded body is limited to maxBytes as well, so that a small compressed body can't
+// expand to an arbitrary size. Afterwards the body of r is the decoded body.
+func (t *Tools) limitBody(w http.ResponseWriter, r *http.Request, maxBytes int) error {
+	if maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	}
+
+	var codings []string
+	for _, value := range r.Header.Values("Content-Encoding") {
+		for _, coding := range strings.Split(value, ",") {
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding != "" && coding != "identity" {
+				codings = append(codings, coding)
+			}
+		}
+	}
+	if len(codings) == 0 {
+		return nil
+	}
+
+	var body io.Reader = r.Body
+	// Codings are listed in the order they were applied.
+	for i := len(codings) - 1; i >= 0; i-- {
+		d, ok := t.decompressor(codings[i])
+		if !ok {
+			supported := t.supportedEncodings()
+			w.Header().Set("Accept-Encoding", strings.Join(supported, ", "))
+			return &UnsupportedEncodingError{Encoding: codings[i], Supported: supported}
+		}
+
+		decoded, err := d(body)
+		if err != nil {
+			return encodingError(codings[i], err)
+		}
+		body = &decodingReader{r: decoded, coding: codings[i]}
+	}
+
+	if maxBytes > 0 {
+		body = &decodedLimitReader{r: body, remaining: int64(maxBytes), limit: int64(maxBytes)}
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{body, r.Body}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return nil
+}
+
+// encodingError wraps an error of reading coding as *ContentEncodingError, unless it is caused by the
+// size limit.
+func encodingError(coding string, err error) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return &JSONTooLargeError{Limit: maxBytesError.Limit}
+	}
+	var tooLarge *JSONTooLargeError
+	if errors.As(err, &tooLarge) {
+		return err
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return &ContentEncodingError{Encoding: coding, Err: err}
+}
+
+// decodingReader turns the errors of a decompressor into *ContentEncodingError.
+type decodingReader struct {
+	r      io.Reader
+	coding string
+}
+
+// Read implements io.Reader.
+func (d *decodingReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = encodingError(d.coding, err)
+	}
+	return n, err
+}
+
+// decodedLimitReader fails with *JSONTooLargeError once more than limit bytes have been read.
+type decodedLimitReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+// Read implements io.Reader.
+func (l *decodedLimitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, &JSONTooLargeError{Limit: l.limit}
+	}
+	// Read one byte more than allowed to detect bodies exceeding the limit.
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), &JSONTooLargeError{Limit: l.limit}
+	}
+	return n, err
+}
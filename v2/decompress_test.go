@@ -0,0 +1,166 @@
+package toolkit
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, _ = gw.Write(data)
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zlibData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, _ = zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func flateData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	_, _ = fw.Write(data)
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func brotliData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	bw := brotli.NewWriter(&buf)
+	_, _ = bw.Write(data)
+	if err := bw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadJSONCompressed(t *testing.T) {
+	valid := []byte(`{"foo": "bar"}`)
+	bomb := []byte(`{"foo": "` + strings.Repeat("0", 10*1024*1024) + `"}`)
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		tool     Tools
+		status   int
+	}{
+		{name: "gzip", encoding: "gzip", body: gzipData(t, valid)},
+		{name: "x-gzip", encoding: "x-gzip", body: gzipData(t, valid)},
+		{name: "deflate", encoding: "deflate", body: zlibData(t, valid)},
+		{name: "raw deflate", encoding: "deflate", body: flateData(t, valid)},
+		{name: "brotli", encoding: "br", body: brotliData(t, valid)},
+		{name: "identity", encoding: "identity", body: valid},
+		{name: "stacked", encoding: "deflate, gzip", body: gzipData(t, zlibData(t, valid))},
+		{name: "registered", encoding: "zstd", body: gzipData(t, valid), tool: Tools{Decompressors: map[string]Decompressor{"zstd": gzipDecompressor}}},
+		{name: "bomb", encoding: "gzip", body: gzipData(t, bomb), tool: Tools{MaxJSONSize: 1024}, status: http.StatusRequestEntityTooLarge},
+		{name: "brotli bomb", encoding: "br", body: brotliData(t, bomb), tool: Tools{MaxJSONSize: 1024}, status: http.StatusRequestEntityTooLarge},
+		{name: "unsupported", encoding: "zstd", body: valid, status: http.StatusUnsupportedMediaType},
+		{name: "corrupt", encoding: "gzip", body: []byte("not gzip at all"), status: http.StatusBadRequest},
+		{name: "truncated", encoding: "gzip", body: gzipData(t, valid)[:20], status: http.StatusBadRequest},
+		{name: "empty", encoding: "gzip", status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(test.body))
+		req.Header.Set("Content-Encoding", test.encoding)
+		rr := httptest.NewRecorder()
+
+		var payload struct {
+			Foo string `json:"foo"`
+		}
+		err := test.tool.ReadJSON(rr, req, &payload)
+
+		if test.status == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v\n", test.name, err)
+			} else if payload.Foo != "bar" {
+				t.Errorf("%s: expected bar, got %q\n", test.name, payload.Foo)
+			}
+			continue
+		}
+
+		var statusErr StatusCoder
+		if !errors.As(err, &statusErr) || statusErr.StatusCode() != test.status {
+			t.Errorf("%s: expected error with status %d, got %v\n", test.name, test.status, err)
+		}
+		if test.status == http.StatusUnsupportedMediaType && rr.Header().Get("Accept-Encoding") != "br, deflate, gzip, x-gzip" {
+			t.Errorf("%s: expected Accept-Encoding header, got %q\n", test.name, rr.Header().Get("Accept-Encoding"))
+		}
+	}
+}
+
+func TestReadBodyCompressed(t *testing.T) {
+	tool := Tools{Codecs: allCodecs}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(gzipData(t, []byte("name: Widget\ncount: 3\n"))))
+	req.Header.Set("Content-Type", "application/yaml")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	var item codecItem
+	if err := tool.ReadBody(httptest.NewRecorder(), req, &item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Name != "Widget" || item.Count != 3 {
+		t.Errorf("unexpected result %+v\n", item)
+	}
+}
+
+func TestReadNDJSONCompressedBomb(t *testing.T) {
+	// Short lines compress extremely well; only the limit of the whole body stops them.
+	bomb := gzipData(t, bytes.Repeat([]byte("{}\n"), 1024*1024))
+	tool := Tools{MaxNDJSONSize: 64 * 1024}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(bomb))
+	req.Header.Set("Content-Encoding", "gzip")
+
+	lines := 0
+	err := ReadNDJSON(&tool, httptest.NewRecorder(), req, func(v struct{}) error {
+		lines++
+		return nil
+	})
+
+	var tooLarge *JSONTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 64*1024 {
+		t.Errorf("expected *JSONTooLargeError, got %v\n", err)
+	}
+	if lines > 64*1024/3 {
+		t.Errorf("read %d lines beyond the limit\n", lines)
+	}
+}
+
+func TestDecodedLimitReader(t *testing.T) {
+	l := &decodedLimitReader{r: strings.NewReader("0123456789"), remaining: 5, limit: 5}
+	out, err := io.ReadAll(l)
+
+	var tooLarge *JSONTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Errorf("expected *JSONTooLargeError, got %v\n", err)
+	}
+	if string(out) != "01234" {
+		t.Errorf("expected the first 5 bytes, got %q\n", out)
+	}
+}
@@ -0,0 +1,93 @@
+package toolkit
+
+import "strings"
+
+// Dispositions for Tools.Disposition and ContentDisposition.
+const (
+	DispositionAttachment = "attachment"
+	DispositionInline     = "inline"
+)
+
+// ContentDisposition builds a Content-Disposition header value according to RFC 6266. disposition is
+// either DispositionInline or DispositionAttachment; any other value is treated as attachment.
+// ASCII file names are sent as quoted filename parameter with quotes and backslashes escaped. For other
+// names an ASCII fallback is sent as filename and the original name as UTF-8 encoded filename* parameter
+// (RFC 5987), which all current browsers prefer.
+func ContentDisposition(disposition, filename string) string {
+	if disposition != DispositionInline {
+		disposition = DispositionAttachment
+	}
+	if filename == "" {
+		return disposition
+	}
+
+	if isPrintableASCII(filename) {
+		return disposition + `; filename="` + quoteEscape(filename) + `"`
+	}
+
+	return disposition + `; filename="` + quoteEscape(asciiFallback(filename)) + `"; filename*=UTF-8''` + encodeRFC5987(filename)
+}
+
+// disposition returns the configured disposition of downloads.
+func (t *Tools) disposition() string {
+	if t.Disposition == "" {
+		return DispositionAttachment
+	}
+	return t.Disposition
+}
+
+// isPrintableASCII reports whether s consists of printable ASCII characters only.
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// quoteEscape escapes quotes and backslashes for use in a quoted-string.
+func quoteEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// asciiFallback transliterates s to ASCII and replaces all remaining non-printable or non-ASCII
+// characters by an underscore.
+func asciiFallback(s string) string {
+	s = transliterate(s, genericRules)
+	var b strings.Builder
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			b.WriteByte('_')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// encodeRFC5987 percent-encodes every byte of s that is not an attr-char as defined in RFC 5987.
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
+
+// isAttrChar reports whether c may appear unencoded in an RFC 5987 ext-value.
+func isAttrChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,69 @@
+package toolkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name        string
+		disposition string
+		filename    string
+		want        string
+	}{
+		{name: "ascii", disposition: DispositionAttachment, filename: "kitten.png", want: `attachment; filename="kitten.png"`},
+		{name: "inline", disposition: DispositionInline, filename: "kitten.png", want: `inline; filename="kitten.png"`},
+		{name: "unknown disposition", disposition: "bogus", filename: "a.txt", want: `attachment; filename="a.txt"`},
+		{name: "no file name", disposition: DispositionInline, filename: "", want: `inline`},
+		{name: "quotes", disposition: DispositionAttachment, filename: `my "best" \ file.txt`, want: `attachment; filename="my \"best\" \\ file.txt"`},
+		{
+			name:        "non-ascii",
+			disposition: DispositionAttachment,
+			filename:    "Crème brûlée.pdf",
+			want:        `attachment; filename="Creme brulee.pdf"; filename*=UTF-8''Cr%C3%A8me%20br%C3%BBl%C3%A9e.pdf`,
+		},
+		{
+			name:        "non-latin",
+			disposition: DispositionInline,
+			filename:    "报告.pdf",
+			want:        `inline; filename="__.pdf"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.pdf`,
+		},
+		{
+			name:        "header injection",
+			disposition: DispositionAttachment,
+			filename:    "a\r\nSet-Cookie: x.txt",
+			want:        `attachment; filename="a__Set-Cookie: x.txt"; filename*=UTF-8''a%0D%0ASet-Cookie%3A%20x.txt`,
+		},
+	}
+
+	for _, test := range tests {
+		got := ContentDisposition(test.disposition, test.filename)
+		if got != test.want {
+			t.Errorf("%s: expected %s, got %s\n", test.name, test.want, got)
+		}
+	}
+}
+
+func TestDownloadStaticFileInline(t *testing.T) {
+	dir := t.TempDir()
+	pathName := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(pathName, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tools := Tools{Disposition: DispositionInline}
+	rr := httptest.NewRecorder()
+	tools.DownloadStaticFile(rr, httptest.NewRequest("GET", "/", nil), pathName, "Grüße.txt")
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d\n", http.StatusOK, rr.Code)
+	}
+	want := `inline; filename="Grusse.txt"; filename*=UTF-8''Gr%C3%BC%C3%9Fe.txt`
+	if got := rr.Header().Get("Content-Disposition"); got != want {
+		t.Errorf("Expected Content-Disposition %s, got %s\n", want, got)
+	}
+}
@@ -0,0 +1,224 @@
+package toolkit
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrOutsideDownloadRoot is returned if a requested file is not located below the download root.
+	ErrOutsideDownloadRoot = errors.New("file is outside of the download root")
+	// ErrExtensionNotAllowed is returned if the extension of a requested file is not permitted.
+	ErrExtensionNotAllowed = errors.New("file extension is not permitted")
+	// ErrTooManyDownloads is returned if the DownloadLimiter's maximum of concurrent downloads is reached.
+	ErrTooManyDownloads = errors.New("too many concurrent downloads")
+)
+
+// DownloadError is returned by the download functions if a file cannot be served. Status is the HTTP
+// status code to answer with, Err the underlying cause, e.g. ErrOutsideDownloadRoot or fs.ErrNotExist.
+type DownloadError struct {
+	Name   string
+	Status int
+	Err    error
+}
+
+// Error implements the error interface.
+func (e *DownloadError) Error() string {
+	return fmt.Sprintf("download of %q failed: %v", e.Name, e.Err)
+}
+
+// Unwrap returns the underlying cause, so that errors.Is(err, ErrOutsideDownloadRoot) works.
+func (e *DownloadError) Unwrap() error {
+	return e.Err
+}
+
+// DownloadStaticFileFromRoot works like DownloadStaticFile, but confines the download to DownloadRoot.
+// file is interpreted relative to the root and symbolic links are resolved before the check, so neither
+// "../" sequences nor links can escape the root. If AllowedDownloadExtensions is set, only files with one of
+// these extensions are served. Directories are never served.
+// On failure nothing is written, like for ReadJSON; the caller answers with the status of the returned
+// *DownloadError, e.g. by ErrorJSON: 403 for files outside the root or with a disallowed extension, 404
+// for files that don't exist and 429 if the DownloadLimiter is busy, with Retry-After set already.
+func (t *Tools) DownloadStaticFileFromRoot(w http.ResponseWriter, r *http.Request, file, displayName string) error {
+	filePath, err := t.resolveDownload(file)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return &DownloadError{Name: file, Status: http.StatusNotFound, Err: err}
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return &DownloadError{Name: file, Status: http.StatusInternalServerError, Err: err}
+	}
+
+	return t.serveDownload(w, r, f, fi.Name(), displayName, fi.ModTime(), fi.Size())
+}
+
+// DownloadFromFS works like DownloadStaticFileFromRoot, but serves the file name out of fsys, e.g. an
+// embed.FS or os.DirFS. name is a slash separated path; a leading slash is ignored. Names that are not
+// valid fs paths (see fs.ValidPath), such as "../x", are rejected with ErrOutsideDownloadRoot.
+// AllowedDownloadExtensions applies as well. Range requests, ETag and Last-Modified are handled like
+// for files on disk.
+func (t *Tools) DownloadFromFS(w http.ResponseWriter, r *http.Request, fsys fs.FS, name, displayName string) error {
+	name = strings.TrimPrefix(name, "/")
+	if !fs.ValidPath(name) {
+		return &DownloadError{Name: name, Status: http.StatusForbidden, Err: ErrOutsideDownloadRoot}
+	}
+	if !t.extensionAllowed(name) {
+		return &DownloadError{Name: name, Status: http.StatusForbidden, Err: ErrExtensionNotAllowed}
+	}
+
+	content, fi, err := openStatic(fsys, name)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	return t.serveDownload(w, r, content, path.Base(name), displayName, fi.ModTime(), fi.Size())
+}
+
+// DownloadContent serves content as download with displayName as file name, e.g. a file read from
+// a non-local storage. modtime is sent as Last-Modified unless it is zero; size is the length of the
+// content or -1 if unknown. Range requests and conditional requests are handled by http.ServeContent.
+// Unless the caller has set an ETag header already, an ETag is derived from size and modtime or, if
+// modtime is zero, from a hash of the content. Errors are returned like by DownloadStaticFileFromRoot.
+func (t *Tools) DownloadContent(w http.ResponseWriter, r *http.Request, content io.ReadSeeker, displayName string, modtime time.Time, size int64) error {
+	return t.serveDownload(w, r, content, displayName, displayName, modtime, size)
+}
+
+// serveDownload sets the Content-Disposition and ETag headers and serves content. name is used to
+// determine the Content-Type. The validators are only set once the DownloadLimiter has admitted the
+// request, so that a 429 response doesn't carry them.
+func (t *Tools) serveDownload(w http.ResponseWriter, r *http.Request, content io.ReadSeeker, name, displayName string, modtime time.Time, size int64) error {
+	w, release, ok := t.limitDownload(w, r)
+	if !ok {
+		return &DownloadError{Name: displayName, Status: http.StatusTooManyRequests, Err: ErrTooManyDownloads}
+	}
+	defer release()
+
+	if w.Header().Get("ETag") == "" {
+		etag, err := contentETag(content, modtime, size)
+		if err != nil {
+			return &DownloadError{Name: displayName, Status: http.StatusInternalServerError, Err: err}
+		}
+		w.Header().Set("ETag", etag)
+	}
+
+	w.Header().Set("Content-Disposition", ContentDisposition(t.disposition(), displayName))
+	http.ServeContent(w, r, name, modtime, content)
+	return nil
+}
+
+// contentETag returns a strong ETag of the content. Like common web servers it is derived from size and
+// modification time; without the latter, the content is hashed and content is rewound afterwards.
+func contentETag(content io.ReadSeeker, modtime time.Time, size int64) (string, error) {
+	if size < 0 {
+		var err error
+		if size, err = content.Seek(0, io.SeekEnd); err != nil {
+			return "", err
+		}
+		if _, err = content.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+
+	if !modtime.IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, modtime.UnixNano(), size), nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`"%x"`, h.Sum(nil)[:16]), nil
+}
+
+// extensionAllowed reports whether name has one of the AllowedDownloadExtensions or whether all
+// extensions are allowed.
+func (t *Tools) extensionAllowed(name string) bool {
+	if len(t.AllowedDownloadExtensions) == 0 {
+		return true
+	}
+	ext := filepath.Ext(name)
+	for _, e := range t.AllowedDownloadExtensions {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDownload returns the real path of file below DownloadRoot or a *DownloadError.
+func (t *Tools) resolveDownload(file string) (string, error) {
+	if t.DownloadRoot == "" {
+		return "", &DownloadError{Name: file, Status: http.StatusInternalServerError, Err: errors.New("no download root configured")}
+	}
+
+	root, err := filepath.Abs(t.DownloadRoot)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return "", &DownloadError{Name: file, Status: http.StatusInternalServerError, Err: err}
+	}
+
+	// Reject "../" before touching the file system, then once more after resolving symbolic links.
+	filePath := filepath.Join(root, filepath.FromSlash(file))
+	if !isBelow(root, filePath) {
+		return "", &DownloadError{Name: file, Status: http.StatusForbidden, Err: ErrOutsideDownloadRoot}
+	}
+	filePath, err = filepath.EvalSymlinks(filePath)
+	if err != nil {
+		return "", &DownloadError{Name: file, Status: http.StatusNotFound, Err: fs.ErrNotExist}
+	}
+	if !isBelow(root, filePath) {
+		return "", &DownloadError{Name: file, Status: http.StatusForbidden, Err: ErrOutsideDownloadRoot}
+	}
+
+	fi, err := os.Stat(filePath)
+	if err != nil || fi.IsDir() {
+		return "", &DownloadError{Name: file, Status: http.StatusNotFound, Err: fs.ErrNotExist}
+	}
+
+	if !t.extensionAllowed(filePath) {
+		return "", &DownloadError{Name: file, Status: http.StatusForbidden, Err: ErrExtensionNotAllowed}
+	}
+
+	return filePath, nil
+}
+
+// isBelow reports whether path is root itself or located below root. Both must be clean, absolute paths.
+func isBelow(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// writeDownloadError answers with the status of err, for the download functions that can't return it.
+func writeDownloadError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var downloadErr *DownloadError
+	if errors.As(err, &downloadErr) {
+		status = downloadErr.Status
+	}
+	http.Error(w, http.StatusText(status), status)
+}
@@ -0,0 +1,202 @@
+package toolkit
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// setupDownloadRoot creates a download root with a few files and a symbolic link pointing outside of it.
+func setupDownloadRoot(t *testing.T) string {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+
+	files := map[string]string{
+		filepath.Join(root, "report.pdf"):      "%PDF-1.4 report",
+		filepath.Join(root, "docs", "a.txt"):   "hello",
+		filepath.Join(root, "notes.txt"):       "notes",
+		filepath.Join(base, "secret", "p.txt"): "secret",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(base, "secret", "p.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "docs", "a.txt"), filepath.Join(root, "inner.txt")); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// downloadStatus returns the status a download answers with: the status written to rr or, if the
+// download failed, the status of err, which must have been left to the caller to write.
+func downloadStatus(t *testing.T, rr *httptest.ResponseRecorder, err error) int {
+	t.Helper()
+	if err == nil {
+		return rr.Code
+	}
+	if rr.Body.Len() > 0 {
+		t.Errorf("expected nothing to be written for %v, got %q\n", err, rr.Body.String())
+	}
+	var statusErr StatusCoder
+	if !errors.As(err, &statusErr) {
+		t.Errorf("expected a StatusCoder, got %v\n", err)
+		return 0
+	}
+	return statusErr.StatusCode()
+}
+
+func TestDownloadStaticFileFromRoot(t *testing.T) {
+	root := setupDownloadRoot(t)
+
+	tests := []struct {
+		name       string
+		file       string
+		extensions []string
+		status     int
+		err        error
+	}{
+		{name: "file in root", file: "report.pdf", status: http.StatusOK},
+		{name: "file in sub directory", file: "docs/a.txt", status: http.StatusOK},
+		{name: "link inside root", file: "inner.txt", status: http.StatusOK},
+		{name: "traversal", file: "../secret/p.txt", status: http.StatusForbidden, err: ErrOutsideDownloadRoot},
+		{name: "deep traversal", file: "docs/../../../../etc/passwd", status: http.StatusForbidden, err: ErrOutsideDownloadRoot},
+		{name: "link outside root", file: "link.txt", status: http.StatusForbidden, err: ErrOutsideDownloadRoot},
+		{name: "not existing", file: "missing.txt", status: http.StatusNotFound, err: fs.ErrNotExist},
+		{name: "directory", file: "docs", status: http.StatusNotFound, err: fs.ErrNotExist},
+		{name: "allowed extension", file: "report.pdf", extensions: []string{".PDF"}, status: http.StatusOK},
+		{name: "disallowed extension", file: "notes.txt", extensions: []string{".pdf"}, status: http.StatusForbidden, err: ErrExtensionNotAllowed},
+	}
+
+	for _, test := range tests {
+		tools := Tools{DownloadRoot: root, AllowedDownloadExtensions: test.extensions}
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+
+		err := tools.DownloadStaticFileFromRoot(rr, r, test.file, "download")
+		if status := downloadStatus(t, rr, err); status != test.status {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, test.status, status)
+		}
+		if test.err == nil && err != nil {
+			t.Errorf("%s: unexpected error %v\n", test.name, err)
+		}
+		if test.err != nil {
+			var downloadErr *DownloadError
+			if !errors.As(err, &downloadErr) || !errors.Is(err, test.err) {
+				t.Errorf("%s: expected %v, got %v\n", test.name, test.err, err)
+			}
+		}
+	}
+}
+
+func TestDownloadStaticFileWithRoot(t *testing.T) {
+	root := setupDownloadRoot(t)
+	tools := Tools{DownloadRoot: root}
+
+	rr := httptest.NewRecorder()
+	tools.DownloadStaticFile(rr, httptest.NewRequest("GET", "/", nil), "../secret/p.txt", "p.txt")
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d\n", http.StatusForbidden, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	tools.DownloadStaticFile(rr, httptest.NewRequest("GET", "/", nil), "docs/a.txt", "a.txt")
+	if rr.Code != http.StatusOK || rr.Body.String() != "hello" {
+		t.Errorf("Expected file content, got %d %q\n", rr.Code, rr.Body.String())
+	}
+}
+
+func TestDownloadFromFS(t *testing.T) {
+	modtime := time.Date(2023, 3, 12, 10, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"files/report.txt": {Data: []byte("0123456789"), ModTime: modtime},
+		"files/embedded":   {Data: []byte("no modification time")},
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		headers map[string]string
+		status  int
+		body    string
+	}{
+		{name: "full file", file: "files/report.txt", status: http.StatusOK, body: "0123456789"},
+		{name: "leading slash", file: "/files/report.txt", status: http.StatusOK, body: "0123456789"},
+		{name: "range", file: "files/report.txt", headers: map[string]string{"Range": "bytes=2-4"}, status: http.StatusPartialContent, body: "234"},
+		{name: "not modified", file: "files/report.txt", headers: map[string]string{"If-Modified-Since": modtime.Format(http.TimeFormat)}, status: http.StatusNotModified},
+		{name: "zero modtime", file: "files/embedded", status: http.StatusOK, body: "no modification time"},
+		{name: "traversal", file: "../files/report.txt", status: http.StatusForbidden},
+		{name: "missing", file: "files/missing.txt", status: http.StatusNotFound},
+		{name: "directory", file: "files", status: http.StatusNotFound},
+	}
+
+	var tools Tools
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+
+		err := tools.DownloadFromFS(rr, r, fsys, test.file, "report.txt")
+		if status := downloadStatus(t, rr, err); status != test.status {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, test.status, status)
+		}
+		if test.body != "" && rr.Body.String() != test.body {
+			t.Errorf("%s: expected body %q, got %q\n", test.name, test.body, rr.Body.String())
+		}
+		if err == nil && rr.Code == http.StatusOK && rr.Header().Get("ETag") == "" {
+			t.Errorf("%s: ETag missing\n", test.name)
+		}
+	}
+}
+
+func TestDownloadContentETag(t *testing.T) {
+	var tools Tools
+	modtime := time.Date(2023, 3, 12, 10, 0, 0, 0, time.UTC)
+
+	rr := httptest.NewRecorder()
+	err := tools.DownloadContent(rr, httptest.NewRequest("GET", "/", nil), strings.NewReader("hello world"), "hello.txt", modtime, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("ETag missing")
+	}
+	if got := rr.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("Unexpected Content-Type %s\n", got)
+	}
+
+	// A matching If-None-Match results in 304 Not Modified
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	_ = tools.DownloadContent(rr, r, strings.NewReader("hello world"), "hello.txt", modtime, 11)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d\n", http.StatusNotModified, rr.Code)
+	}
+
+	// If-Range with the ETag keeps the Range request valid
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Range", "bytes=0-4")
+	r.Header.Set("If-Range", etag)
+	rr = httptest.NewRecorder()
+	_ = tools.DownloadContent(rr, r, strings.NewReader("hello world"), "hello.txt", modtime, 11)
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "hello" {
+		t.Errorf("Expected partial content, got %d %q\n", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,136 @@
+package toolkit
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheOptions controls the caching of a response written by WriteJSONCached.
+type CacheOptions struct {
+	// ETag is the entity tag of the response, e.g. a version number or revision of the resource. It is
+	// quoted if necessary; prefix it with W/ for a weak ETag. Without ETag, a strong ETag is computed
+	// from the JSON encoding of the data.
+	ETag string
+	// CacheControl is sent as Cache-Control header, e.g. "private, max-age=60" or "no-cache".
+	CacheControl string
+}
+
+// PreconditionFailedError is returned if the If-Match or If-None-Match header of a request doesn't hold
+// for the current ETag of the resource, which is empty if the resource doesn't exist.
+type PreconditionFailedError struct {
+	ETag string
+}
+
+// Error implements the error interface.
+func (e *PreconditionFailedError) Error() string {
+	if e.ETag == "" {
+		return "precondition failed: resource does not exist"
+	}
+	return "precondition failed: resource has ETag " + e.ETag
+}
+
+// StatusCode returns http.StatusPreconditionFailed.
+func (e *PreconditionFailedError) StatusCode() int { return http.StatusPreconditionFailed }
+
+// JSONETag returns a strong ETag for the JSON encoding of data, as used by WriteJSONCached with the
+// default JSON options.
+func JSONETag(data interface{}) (string, error) {
+	out, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return payloadETag(out), nil
+}
+
+// payloadETag returns a strong ETag for the response body out.
+func payloadETag(out []byte) string {
+	sum := sha256.Sum256(out)
+	return fmt.Sprintf(`"%x"`, sum[:16])
+}
+
+// quoteETag turns a version into an entity tag unless it is one already.
+func quoteETag(etag string) string {
+	weak := strings.HasPrefix(etag, "W/")
+	opaque := strings.TrimPrefix(etag, "W/")
+	if len(opaque) < 2 || opaque[0] != '"' || opaque[len(opaque)-1] != '"' {
+		opaque = strconv.Quote(opaque)
+	}
+	if weak {
+		return "W/" + opaque
+	}
+	return opaque
+}
+
+// CheckPreconditions evaluates the If-Match and If-None-Match headers of a request that modifies a
+// resource, before it is modified. etag is the current ETag of the resource, or "" if it doesn't exist,
+// so that "If-None-Match: *" guards against overwriting an existing resource. A failed precondition is
+// returned as *PreconditionFailedError; the handler must not modify the resource then. For GET and HEAD
+// requests, WriteJSONCached evaluates the headers itself.
+func (t *Tools) CheckPreconditions(r *http.Request, etag string) error {
+	if etag != "" {
+		etag = quoteETag(etag)
+	}
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if etag == "" || !etagMatches(im, etag, false) {
+			return &PreconditionFailedError{ETag: etag}
+		}
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etag != "" {
+		if etagMatches(inm, etag, true) {
+			return &PreconditionFailedError{ETag: etag}
+		}
+	}
+	return nil
+}
+
+// WriteJSONCached writes data as JSON like WriteJSON, with the ETag and Cache-Control of opts. For GET
+// and HEAD requests with a 2xx status, conditional requests are answered without a body: 304 Not
+// Modified if If-None-Match matches the ETag, and 412 Precondition Failed if If-Match doesn't. Handlers
+// modifying a resource check If-Match with CheckPreconditions before the modification instead.
+func (t *Tools) WriteJSONCached(w http.ResponseWriter, r *http.Request, status int, data interface{}, opts CacheOptions, headers ...http.Header) error {
+	body, err := t.encodeJSON(r, data)
+	if err != nil {
+		return err
+	}
+	defer body.release()
+
+	if err := t.validateResponse(data, body.JSON()); err != nil {
+		return err
+	}
+	if len(headers) > 0 {
+		for k, v := range headers[0] {
+			w.Header()[k] = v
+		}
+	}
+
+	etag := payloadETag(body.Bytes())
+	if opts.ETag != "" {
+		etag = quoteETag(opts.ETag)
+	}
+	if status >= 200 && status < 300 {
+		w.Header().Set("ETag", etag)
+	}
+	if opts.CacheControl != "" {
+		w.Header().Set("Cache-Control", opts.CacheControl)
+	}
+
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && status >= 200 && status < 300 {
+		if im := r.Header.Get("If-Match"); im != "" && !etagMatches(im, etag, false) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return nil
+		}
+		if notModified(r, etag, time.Time{}) {
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return t.writeBody(w, r, status, body.Bytes())
+}
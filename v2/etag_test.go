@@ -0,0 +1,106 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteJSONCached(t *testing.T) {
+	var tool Tools
+	data := map[string]string{"name": "Alice"}
+	etag, err := JSONETag(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		status   int
+		opts     CacheOptions
+		header   http.Header
+		want     int
+		wantETag string
+		body     bool
+	}{
+		{name: "computed", method: http.MethodGet, status: http.StatusOK, want: http.StatusOK, wantETag: etag, body: true},
+		{name: "not modified", method: http.MethodGet, status: http.StatusOK, header: http.Header{"If-None-Match": {`"x", ` + etag}}, want: http.StatusNotModified, wantETag: etag},
+		{name: "weak not modified", method: http.MethodHead, status: http.StatusOK, header: http.Header{"If-None-Match": {"W/" + etag}}, want: http.StatusNotModified, wantETag: etag},
+		{name: "modified", method: http.MethodGet, status: http.StatusOK, header: http.Header{"If-None-Match": {`"x"`}}, want: http.StatusOK, wantETag: etag, body: true},
+		{name: "version", method: http.MethodGet, status: http.StatusOK, opts: CacheOptions{ETag: "v7"}, header: http.Header{"If-None-Match": {`"v7"`}}, want: http.StatusNotModified, wantETag: `"v7"`},
+		{name: "weak version", method: http.MethodGet, status: http.StatusOK, opts: CacheOptions{ETag: `W/"v7"`}, want: http.StatusOK, wantETag: `W/"v7"`, body: true},
+		{name: "if-match", method: http.MethodGet, status: http.StatusOK, header: http.Header{"If-Match": {etag}}, want: http.StatusOK, wantETag: etag, body: true},
+		{name: "if-match fails", method: http.MethodGet, status: http.StatusOK, header: http.Header{"If-Match": {`"x"`}}, want: http.StatusPreconditionFailed, wantETag: etag},
+		{name: "if-match weak", method: http.MethodGet, status: http.StatusOK, opts: CacheOptions{ETag: `W/"v7"`}, header: http.Header{"If-Match": {`W/"v7"`}}, want: http.StatusPreconditionFailed, wantETag: `W/"v7"`},
+		{name: "unsafe method", method: http.MethodPut, status: http.StatusOK, header: http.Header{"If-None-Match": {etag}}, want: http.StatusOK, wantETag: etag, body: true},
+		{name: "error status", method: http.MethodGet, status: http.StatusNotFound, header: http.Header{"If-None-Match": {"*"}}, want: http.StatusNotFound, body: true},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/", nil)
+		for k, v := range test.header {
+			req.Header[k] = v
+		}
+		opts := test.opts
+		opts.CacheControl = "private, max-age=60"
+
+		rr := httptest.NewRecorder()
+		if err := tool.WriteJSONCached(rr, req, test.status, data, opts); err != nil {
+			t.Errorf("%s: unexpected error: %v\n", test.name, err)
+			continue
+		}
+
+		if rr.Code != test.want {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, test.want, rr.Code)
+		}
+		if got := rr.Header().Get("ETag"); got != test.wantETag {
+			t.Errorf("%s: expected ETag %s, got %s\n", test.name, test.wantETag, got)
+		}
+		if got := rr.Header().Get("Cache-Control"); got != "private, max-age=60" {
+			t.Errorf("%s: unexpected Cache-Control %q\n", test.name, got)
+		}
+		if test.body != (rr.Body.String() == `{"name":"Alice"}`) {
+			t.Errorf("%s: unexpected body %q\n", test.name, rr.Body.String())
+		}
+	}
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	var tool Tools
+
+	tests := []struct {
+		name   string
+		header http.Header
+		etag   string
+		fails  bool
+	}{
+		{name: "no conditions", etag: "v1"},
+		{name: "if-match", header: http.Header{"If-Match": {`"v1"`}}, etag: "v1"},
+		{name: "if-match stale", header: http.Header{"If-Match": {`"v0"`}}, etag: "v1", fails: true},
+		{name: "if-match any", header: http.Header{"If-Match": {"*"}}, etag: `"v1"`},
+		{name: "if-match missing", header: http.Header{"If-Match": {"*"}}, fails: true},
+		{name: "if-match weak", header: http.Header{"If-Match": {`W/"v1"`}}, etag: `W/"v1"`, fails: true},
+		{name: "create only", header: http.Header{"If-None-Match": {"*"}}},
+		{name: "create existing", header: http.Header{"If-None-Match": {"*"}}, etag: "v1", fails: true},
+		{name: "if-none-match", header: http.Header{"If-None-Match": {`"v0"`}}, etag: "v1"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/", nil)
+		for k, v := range test.header {
+			req.Header[k] = v
+		}
+
+		err := tool.CheckPreconditions(req, test.etag)
+		var preconditionErr *PreconditionFailedError
+		if test.fails {
+			if !errors.As(err, &preconditionErr) || preconditionErr.StatusCode() != http.StatusPreconditionFailed {
+				t.Errorf("%s: expected *PreconditionFailedError, got %v\n", test.name, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %v\n", test.name, err)
+		}
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/jmh-git/toolkit/v2
 
 go 1.19
+
+require github.com/andybalholm/brotli v1.1.1
@@ -0,0 +1,156 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// JSONEncoder encodes values to a writer. *json.Encoder implements it, as do the encoders of most
+// alternative JSON packages.
+type JSONEncoder interface {
+	Encode(v interface{}) error
+	SetEscapeHTML(on bool)
+	SetIndent(prefix, indent string)
+}
+
+// XSSIPrefix is a common prefix guarding JSON responses against cross-site script inclusion. Clients
+// strip it before parsing.
+const XSSIPrefix = ")]}',\n"
+
+// JSONOptions configure how WriteJSON and the other JSON writing methods encode responses.
+type JSONOptions struct {
+	// Indent pretty prints responses with the given indentation, e.g. "  ".
+	Indent string
+	// PrettyParam pretty prints a response if its request has the query parameter pretty, e.g.
+	// "?pretty" or "?pretty=true", with Indent or two spaces. It applies only to the methods that get
+	// the request: WriteJSONFor, ErrorJSONFor, WriteResponse, WritePage, WriteJSONCached and
+	// ProblemHandler. WriteJSON, ErrorJSON and WriteProblem don't know the request.
+	PrettyParam bool
+	// DisableHTMLEscaping keeps <, > and & in strings instead of escaping them as \u003c etc.
+	DisableHTMLEscaping bool
+	// Prefix is written before the JSON, e.g. XSSIPrefix.
+	Prefix string
+	// NewEncoder creates the encoder, to use another JSON package. The default is json.NewEncoder.
+	NewEncoder func(w io.Writer) JSONEncoder
+}
+
+// WithJSONOptions returns a copy of t that encodes JSON responses with opts instead of t.JSON, for a
+// single call like t.WithJSONOptions(opts).WriteJSON(w, status, data).
+func (t *Tools) WithJSONOptions(opts JSONOptions) *Tools {
+	copied := *t
+	copied.JSON = opts
+	return &copied
+}
+
+// jsonBufferPool holds the buffers responses are encoded into.
+var jsonBufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// maxPooledJSONBuffer is the capacity up to which buffers are returned to the pool, so that a single
+// large response doesn't stay in memory.
+const maxPooledJSONBuffer = 64 * 1024
+
+// jsonBody is an encoded response. It must be released after use.
+type jsonBody struct {
+	buf    *bytes.Buffer
+	prefix int
+}
+
+// JSON returns the encoded data without prefix.
+func (b *jsonBody) JSON() []byte { return b.buf.Bytes()[b.prefix:] }
+
+// Bytes returns the response body.
+func (b *jsonBody) Bytes() []byte { return b.buf.Bytes() }
+
+// release returns the buffer to the pool.
+func (b *jsonBody) release() {
+	if b.buf.Cap() <= maxPooledJSONBuffer {
+		b.buf.Reset()
+		jsonBufferPool.Put(b.buf)
+	}
+	b.buf = nil
+}
+
+// encodeJSON encodes data according to t.JSON for a response to r, which may be nil. Like json.Marshal,
+// the result has no trailing newline.
+func (t *Tools) encodeJSON(r *http.Request, data interface{}) (*jsonBody, error) {
+	return t.JSON.encode(data, t.JSON.Prefix, "", t.jsonIndent(r))
+}
+
+// jsonIndent returns the indentation of a response to r, which may be nil.
+func (t *Tools) jsonIndent(r *http.Request) string {
+	indent := t.JSON.Indent
+	if t.JSON.PrettyParam && r != nil && r.URL.Query().Has("pretty") {
+		if !prettyRequested(r) {
+			// ?pretty=false turns the configured indentation off.
+			indent = ""
+		} else if indent == "" {
+			indent = "  "
+		}
+	}
+	return indent
+}
+
+// encode encodes data after prefix into a pooled buffer. With an indent, lines after the first start
+// with linePrefix; without, the value is a single line.
+func (opts JSONOptions) encode(data interface{}, prefix, linePrefix, indent string) (*jsonBody, error) {
+	buf := jsonBufferPool.Get().(*bytes.Buffer)
+	buf.WriteString(prefix)
+	body := &jsonBody{buf: buf, prefix: len(prefix)}
+
+	var enc JSONEncoder
+	if opts.NewEncoder != nil {
+		enc = opts.NewEncoder(buf)
+	} else {
+		enc = json.NewEncoder(buf)
+	}
+	enc.SetEscapeHTML(!opts.DisableHTMLEscaping)
+	if indent != "" {
+		enc.SetIndent(linePrefix, indent)
+	}
+	if err := enc.Encode(data); err != nil {
+		body.release()
+		return nil, err
+	}
+
+	// Encoders terminate the value with a newline, json.Marshal doesn't.
+	if n := buf.Len(); n > body.prefix && buf.Bytes()[n-1] == '\n' {
+		buf.Truncate(n - 1)
+	}
+	return body, nil
+}
+
+// prettyRequested reports whether the query parameter pretty of r is empty or true.
+func prettyRequested(r *http.Request) bool {
+	value := r.URL.Query().Get("pretty")
+	if value == "" {
+		return true
+	}
+	on, err := strconv.ParseBool(value)
+	return err == nil && on
+}
+
+// writeJSON writes data as JSON for a response to r, which may be nil. Responses to a request are
+// compressed if Compression is set.
+func (t *Tools) writeJSON(w http.ResponseWriter, r *http.Request, status int, data interface{}, headers ...http.Header) error {
+	body, err := t.encodeJSON(r, data)
+	if err != nil {
+		return err
+	}
+	defer body.release()
+
+	if err := t.validateResponse(data, body.JSON()); err != nil {
+		return err
+	}
+	if len(headers) > 0 {
+		for k, v := range headers[0] {
+			w.Header()[k] = v
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return t.writeBody(w, r, status, body.Bytes())
+}
@@ -0,0 +1,180 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteJSONOptions(t *testing.T) {
+	data := map[string]string{"html": "<b>&</b>"}
+
+	tests := []struct {
+		name string
+		opts JSONOptions
+		url  string
+		want string
+	}{
+		{name: "default", want: `{"html":"\u003cb\u003e\u0026\u003c/b\u003e"}`},
+		{name: "indent", opts: JSONOptions{Indent: "  "}, want: "{\n  \"html\": \"\\u003cb\\u003e\\u0026\\u003c/b\\u003e\"\n}"},
+		{name: "no escaping", opts: JSONOptions{DisableHTMLEscaping: true}, want: `{"html":"<b>&</b>"}`},
+		{name: "prefix", opts: JSONOptions{Prefix: XSSIPrefix, DisableHTMLEscaping: true}, want: ")]}',\n" + `{"html":"<b>&</b>"}`},
+		{name: "pretty param", opts: JSONOptions{PrettyParam: true, DisableHTMLEscaping: true}, url: "/?pretty", want: "{\n  \"html\": \"<b>&</b>\"\n}"},
+		{name: "pretty param with indent", opts: JSONOptions{PrettyParam: true, Indent: "\t", DisableHTMLEscaping: true}, url: "/?pretty=1", want: "{\n\t\"html\": \"<b>&</b>\"\n}"},
+		{name: "pretty false", opts: JSONOptions{PrettyParam: true, Indent: "\t", DisableHTMLEscaping: true}, url: "/?pretty=false", want: `{"html":"<b>&</b>"}`},
+		{name: "pretty param disabled", opts: JSONOptions{DisableHTMLEscaping: true}, url: "/?pretty", want: `{"html":"<b>&</b>"}`},
+	}
+
+	for _, test := range tests {
+		tool := Tools{JSON: test.opts}
+		url := test.url
+		if url == "" {
+			url = "/"
+		}
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+
+		rr := httptest.NewRecorder()
+		if err := tool.WriteResponse(rr, req, http.StatusOK, data); err != nil {
+			t.Errorf("%s: unexpected error: %v\n", test.name, err)
+			continue
+		}
+		if rr.Body.String() != test.want {
+			t.Errorf("%s: expected %q, got %q\n", test.name, test.want, rr.Body.String())
+		}
+
+		rr = httptest.NewRecorder()
+		_ = tool.WriteJSONFor(rr, req, http.StatusOK, data)
+		if rr.Body.String() != test.want {
+			t.Errorf("%s: WriteJSONFor: expected %q, got %q\n", test.name, test.want, rr.Body.String())
+		}
+
+		// WriteJSON has no request, so ?pretty doesn't apply.
+		if test.url == "" {
+			rr = httptest.NewRecorder()
+			_ = tool.WriteJSON(rr, http.StatusOK, data)
+			if rr.Body.String() != test.want {
+				t.Errorf("%s: WriteJSON: expected %q, got %q\n", test.name, test.want, rr.Body.String())
+			}
+		}
+	}
+}
+
+func TestWithJSONOptions(t *testing.T) {
+	tool := &Tools{JSON: JSONOptions{Indent: "  "}, MaxJSONSize: 10}
+	call := tool.WithJSONOptions(JSONOptions{Prefix: "while(1);"})
+
+	rr := httptest.NewRecorder()
+	_ = call.WriteJSON(rr, http.StatusOK, []int{1, 2})
+	if got := rr.Body.String(); got != "while(1);[1,2]" {
+		t.Errorf("expected per-call options, got %q\n", got)
+	}
+	if tool.JSON.Prefix != "" || call.MaxJSONSize != 10 {
+		t.Error("expected a copy with the other settings")
+	}
+
+	rr = httptest.NewRecorder()
+	_ = tool.WriteJSON(rr, http.StatusOK, []int{1, 2})
+	if got := rr.Body.String(); got != "[\n  1,\n  2\n]" {
+		t.Errorf("expected Tools options, got %q\n", got)
+	}
+}
+
+// upperEncoder is a JSONEncoder wrapping encoding/json which records its configuration.
+type upperEncoder struct {
+	w       io.Writer
+	escape  bool
+	indent  string
+	encoded int
+}
+
+func (e *upperEncoder) Encode(v interface{}) error {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.encoded++
+	_, err = io.WriteString(e.w, strings.ToUpper(string(out))+"\n")
+	return err
+}
+
+func (e *upperEncoder) SetEscapeHTML(on bool)           { e.escape = on }
+func (e *upperEncoder) SetIndent(prefix, indent string) { e.indent = indent }
+
+func TestJSONOptionsNewEncoder(t *testing.T) {
+	var enc *upperEncoder
+	tool := Tools{JSON: JSONOptions{NewEncoder: func(w io.Writer) JSONEncoder {
+		enc = &upperEncoder{w: w}
+		return enc
+	}}}
+
+	rr := httptest.NewRecorder()
+	if err := tool.WriteJSON(rr, http.StatusOK, map[string]string{"a": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Body.String() != `{"A":"B"}` || enc.encoded != 1 || !enc.escape {
+		t.Errorf("unexpected result %q, %+v\n", rr.Body.String(), enc)
+	}
+
+	// Errors of the encoder are returned before anything is written.
+	rr = httptest.NewRecorder()
+	err := tool.WriteJSON(rr, http.StatusOK, func() {})
+	var unsupported *json.UnsupportedTypeError
+	if !errors.As(err, &unsupported) || rr.Body.Len() != 0 {
+		t.Errorf("expected *json.UnsupportedTypeError, got %v\n", err)
+	}
+}
+
+func TestWriteProblemJSONOptions(t *testing.T) {
+	tool := Tools{JSON: JSONOptions{Prefix: XSSIPrefix}}
+	rr := httptest.NewRecorder()
+	_ = tool.WriteProblem(rr, NewProblem(http.StatusNotFound, "no such item"))
+
+	body := rr.Body.String()
+	if !strings.HasPrefix(body, XSSIPrefix) {
+		t.Fatalf("expected prefix, got %q", body)
+	}
+	var p Problem
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(body, XSSIPrefix)), &p); err != nil || p.Detail != "no such item" {
+		t.Errorf("unexpected problem %+v, %v\n", p, err)
+	}
+}
+
+func TestErrorJSONForPretty(t *testing.T) {
+	tests := []struct {
+		name string
+		tool Tools
+		want string
+	}{
+		{name: "json response", tool: Tools{JSON: JSONOptions{PrettyParam: true}}, want: "{\n  \"error\": true,\n  \"message\": \"boom\"\n}"},
+		{name: "problem details", tool: Tools{JSON: JSONOptions{PrettyParam: true}, ProblemDetails: true}, want: "{\n  \"detail\": \"boom\","},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/?pretty", nil)
+		rr := httptest.NewRecorder()
+		_ = test.tool.ErrorJSONFor(rr, req, errors.New("boom"))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d\n", test.name, rr.Code)
+		}
+		if !strings.HasPrefix(rr.Body.String(), test.want) {
+			t.Errorf("%s: expected pretty printed body, got %q\n", test.name, rr.Body.String())
+		}
+	}
+}
+
+func BenchmarkWriteJSON(b *testing.B) {
+	var tool Tools
+	data := map[string]interface{}{"id": 1, "name": "Alice", "tags": []string{"a", "b", "c"}}
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w.Body.Reset()
+		_ = tool.WriteJSON(w, http.StatusOK, data)
+	}
+}
@@ -0,0 +1,212 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// StatusCoder is implemented by errors which know the HTTP status code they should be answered with.
+// ErrorJSON uses it if the caller doesn't pass a status explicitly.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// JSONSyntaxError is returned if the body is not well-formed JSON. Line and Column are the 1-based
+// position of the offending character, Offset the number of bytes read before the error occurred.
+type JSONSyntaxError struct {
+	Offset int64
+	Line   int
+	Column int
+	Err    error
+}
+
+// Error implements the error interface.
+func (e *JSONSyntaxError) Error() string {
+	if e.Line == 0 {
+		return "body contains badly-formed JSON"
+	}
+	return fmt.Sprintf("body contains badly-formed JSON (at line %d, column %d)", e.Line, e.Column)
+}
+
+// Unwrap returns the error of the JSON decoder.
+func (e *JSONSyntaxError) Unwrap() error { return e.Err }
+
+// StatusCode returns http.StatusBadRequest.
+func (e *JSONSyntaxError) StatusCode() int { return http.StatusBadRequest }
+
+// JSONTypeError is returned if a JSON value doesn't fit the Go type it is decoded into. Field is the
+// dotted path of the field, e.g. "address.zip"; it is empty if the top level value has the wrong type.
+type JSONTypeError struct {
+	Field    string
+	Expected string
+	Value    string
+	Offset   int64
+	Line     int
+	Column   int
+	Err      error
+}
+
+// Error implements the error interface.
+func (e *JSONTypeError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("body contains incorrect JSON type for field %q", e.Field)
+	}
+	return fmt.Sprintf("body contains incorrect JSON type %s, expected %s (at line %d, column %d)", e.Value, e.Expected, e.Line, e.Column)
+}
+
+// Unwrap returns the error of the JSON decoder.
+func (e *JSONTypeError) Unwrap() error { return e.Err }
+
+// StatusCode returns http.StatusBadRequest.
+func (e *JSONTypeError) StatusCode() int { return http.StatusBadRequest }
+
+// JSONUnknownFieldError is returned if the body contains a key which doesn't match a field and
+// AllowUnknownFields is not set.
+type JSONUnknownFieldError struct {
+	Field string
+}
+
+// Error implements the error interface.
+func (e *JSONUnknownFieldError) Error() string {
+	return fmt.Sprintf("body contains unknown key %q", e.Field)
+}
+
+// StatusCode returns http.StatusBadRequest.
+func (e *JSONUnknownFieldError) StatusCode() int { return http.StatusBadRequest }
+
+// JSONTooLargeError is returned if the body exceeds MaxJSONSize.
+type JSONTooLargeError struct {
+	Limit int64
+}
+
+// Error implements the error interface.
+func (e *JSONTooLargeError) Error() string {
+	return fmt.Sprintf("body must not be larger than %d bytes", e.Limit)
+}
+
+// StatusCode returns http.StatusRequestEntityTooLarge.
+func (e *JSONTooLargeError) StatusCode() int { return http.StatusRequestEntityTooLarge }
+
+// JSONTooManyElementsError is returned by ReadJSONArray if the array has more elements than permitted.
+type JSONTooManyElementsError struct {
+	Limit int
+}
+
+// Error implements the error interface.
+func (e *JSONTooManyElementsError) Error() string {
+	return fmt.Sprintf("body must not contain more than %d elements", e.Limit)
+}
+
+// StatusCode returns http.StatusRequestEntityTooLarge.
+func (e *JSONTooManyElementsError) StatusCode() int { return http.StatusRequestEntityTooLarge }
+
+// JSONEmptyBodyError is returned if the body is empty.
+type JSONEmptyBodyError struct{}
+
+// Error implements the error interface.
+func (e *JSONEmptyBodyError) Error() string { return "body must not be empty" }
+
+// StatusCode returns http.StatusBadRequest.
+func (e *JSONEmptyBodyError) StatusCode() int { return http.StatusBadRequest }
+
+// JSONMultipleValuesError is returned if the body contains more than one JSON value.
+type JSONMultipleValuesError struct{}
+
+// Error implements the error interface.
+func (e *JSONMultipleValuesError) Error() string { return "only one JSON value allowed" }
+
+// StatusCode returns http.StatusBadRequest.
+func (e *JSONMultipleValuesError) StatusCode() int { return http.StatusBadRequest }
+
+// StatusCode returns http.StatusUnprocessableEntity.
+func (v ValidationErrors) StatusCode() int { return http.StatusUnprocessableEntity }
+
+// StatusCode returns the status the download has been answered with.
+func (e *DownloadError) StatusCode() int { return e.Status }
+
+// jsonInput records the bytes of a request body read by the JSON decoder, so that the position of an
+// error can be reported as line and column.
+type jsonInput struct {
+	r        io.Reader
+	read     bytes.Buffer
+	maxBytes int
+}
+
+// Read implements io.Reader.
+func (in *jsonInput) Read(p []byte) (int, error) {
+	n, err := in.r.Read(p)
+	in.read.Write(p[:n])
+	return n, err
+}
+
+// position returns the 1-based line and column of the byte at offset.
+func (in *jsonInput) position(offset int64) (line, column int) {
+	data := in.read.Bytes()
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	data = data[:offset]
+	lineStart := bytes.LastIndexByte(data, '\n') + 1
+	return bytes.Count(data, []byte{'\n'}) + 1, utf8.RuneCount(data[lineStart:]) + 1
+}
+
+// error translates an error of the JSON decoder into one of the JSON error types.
+func (in *jsonInput) error(err error) error {
+	var maxBytesError *http.MaxBytesError
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+	var invalidUnmarshalError *json.InvalidUnmarshalError
+	var tooLargeError *JSONTooLargeError
+	var encodingError *ContentEncodingError
+
+	switch {
+	case errors.As(err, &maxBytesError):
+		return &JSONTooLargeError{Limit: maxBytesError.Limit}
+
+	case errors.As(err, &tooLargeError), errors.As(err, &encodingError):
+		// Errors of decompressing the body.
+		return err
+
+	case errors.As(err, &syntaxError):
+		// Offset counts the offending character, too.
+		line, column := in.position(syntaxError.Offset - 1)
+		return &JSONSyntaxError{Offset: syntaxError.Offset, Line: line, Column: column, Err: err}
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		offset := int64(in.read.Len())
+		line, column := in.position(offset)
+		return &JSONSyntaxError{Offset: offset, Line: line, Column: column, Err: err}
+
+	case errors.As(err, &unmarshalTypeError):
+		line, column := in.position(unmarshalTypeError.Offset)
+		return &JSONTypeError{
+			Field:    unmarshalTypeError.Field,
+			Expected: unmarshalTypeError.Type.String(),
+			Value:    unmarshalTypeError.Value,
+			Offset:   unmarshalTypeError.Offset,
+			Line:     line,
+			Column:   column,
+			Err:      err,
+		}
+
+	case errors.Is(err, io.EOF):
+		return &JSONEmptyBodyError{}
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for unknown fields.
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return &JSONUnknownFieldError{Field: strings.Trim(field, `"`)}
+
+	case errors.As(err, &invalidUnmarshalError):
+		return fmt.Errorf("error unmarshalling JSON: %w", err)
+
+	default:
+		return err
+	}
+}
@@ -0,0 +1,121 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadJSONErrorTypes(t *testing.T) {
+	type address struct {
+		Zip int `json:"zip"`
+	}
+	type payload struct {
+		Foo     string  `json:"foo"`
+		Address address `json:"address"`
+	}
+
+	tests := []struct {
+		name    string
+		json    string
+		maxSize int
+		check   func(err error) bool
+	}{
+		{name: "syntax", json: "{\n  \"foo\": \"bar\",\n  \"address\": }", check: func(err error) bool {
+			var e *JSONSyntaxError
+			return errors.As(err, &e) && e.Line == 3 && e.Column == 14
+		}},
+		{name: "syntax unicode", json: `{"foo": "ä" x}`, check: func(err error) bool {
+			var e *JSONSyntaxError
+			return errors.As(err, &e) && e.Line == 1 && e.Column == 13
+		}},
+		{name: "unexpected end", json: `{"foo": "bar"`, check: func(err error) bool {
+			var e *JSONSyntaxError
+			return errors.As(err, &e)
+		}},
+		{name: "type", json: `{"address": {"zip": "12345"}}`, check: func(err error) bool {
+			var e *JSONTypeError
+			return errors.As(err, &e) && e.Field == "address.zip" && e.Value == "string" && e.Expected == "int"
+		}},
+		{name: "unknown field", json: `{"bar": 1}`, check: func(err error) bool {
+			var e *JSONUnknownFieldError
+			return errors.As(err, &e) && e.Field == "bar"
+		}},
+		{name: "too large", json: `{"foo": "bar"}`, maxSize: 5, check: func(err error) bool {
+			var e *JSONTooLargeError
+			return errors.As(err, &e) && e.Limit == 5
+		}},
+		{name: "empty", json: ``, check: func(err error) bool {
+			var e *JSONEmptyBodyError
+			return errors.As(err, &e)
+		}},
+		{name: "multiple values", json: `{"foo": "a"}{"foo": "b"}`, check: func(err error) bool {
+			var e *JSONMultipleValuesError
+			return errors.As(err, &e)
+		}},
+	}
+
+	for _, test := range tests {
+		tool := Tools{MaxJSONSize: test.maxSize}
+		var data payload
+		err := tool.ReadJSON(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(test.json)), &data)
+		if !test.check(err) {
+			t.Errorf("%s: unexpected error %#v\n", test.name, err)
+		}
+	}
+}
+
+func TestReadJSONArrayErrorTypes(t *testing.T) {
+	tool := Tools{}
+
+	_, err := ReadJSONArray[genericItem](&tool, httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(`{"foo": "a"}`)), 5)
+	var typeErr *JSONTypeError
+	if !errors.As(err, &typeErr) || typeErr.Expected != "array" || typeErr.Value != "object" {
+		t.Errorf("Expected a JSONTypeError, got %#v\n", err)
+	}
+
+	_, err = ReadJSONArray[genericItem](&tool, httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(`[{}, {}]`)), 1)
+	var tooMany *JSONTooManyElementsError
+	if !errors.As(err, &tooMany) || tooMany.Limit != 1 {
+		t.Errorf("Expected a JSONTooManyElementsError, got %#v\n", err)
+	}
+}
+
+func TestErrorJSONStatus(t *testing.T) {
+	tool := Tools{}
+
+	tests := []struct {
+		name   string
+		err    error
+		status []int
+		want   int
+	}{
+		{name: "plain error", err: errors.New("boom"), want: http.StatusBadRequest},
+		{name: "too large", err: &JSONTooLargeError{Limit: 10}, want: http.StatusRequestEntityTooLarge},
+		{name: "syntax", err: &JSONSyntaxError{Line: 1, Column: 1}, want: http.StatusBadRequest},
+		{name: "validation", err: ValidationErrors{{Field: "a", Rule: "required", Message: "is required"}}, want: http.StatusUnprocessableEntity},
+		{name: "download", err: &DownloadError{Status: http.StatusForbidden, Err: ErrOutsideDownloadRoot}, want: http.StatusForbidden},
+		{name: "explicit status wins", err: &JSONTooLargeError{Limit: 10}, status: []int{http.StatusTeapot}, want: http.StatusTeapot},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		if err := tool.ErrorJSON(rr, test.err, test.status...); err != nil {
+			t.Fatal(err)
+		}
+		if rr.Code != test.want {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, test.want, rr.Code)
+		}
+
+		var payload JSONResponse
+		if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.Message != test.err.Error() {
+			t.Errorf("%s: expected message %q, got %q\n", test.name, test.err.Error(), payload.Message)
+		}
+	}
+}
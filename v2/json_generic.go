@@ -0,0 +1,90 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// ReadJSON is the generic variant of Tools.ReadJSON. It decodes the body of a request into a new value
+// of type T and returns it, so the caller doesn't need to declare the variable beforehand.
+func ReadJSON[T any](t *Tools, w http.ResponseWriter, r *http.Request) (T, error) {
+	var data T
+	err := t.ReadJSON(w, r, &data)
+	return data, err
+}
+
+// ReadJSONArray decodes a body consisting of a JSON array into a slice of T. The elements are decoded one
+// by one, so a body with more than maxElements elements is rejected without decoding the rest of it.
+// A maxElements of zero or less means no limit. All other rules of Tools.ReadJSON apply; a schema
+// registered for []T in Tools.Schemas is checked against the whole body before the elements are decoded.
+func ReadJSONArray[T any](t *Tools, w http.ResponseWriter, r *http.Request, maxElements int) ([]T, error) {
+	dec, input, err := t.jsonDecoder(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if schema, ok := t.Schemas.Lookup(new([]T)); ok {
+		if dec, input, err = t.validateSchema(dec, input, schema); err != nil {
+			return nil, err
+		}
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, input.error(err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		line, column := input.position(dec.InputOffset() - 1)
+		return nil, &JSONTypeError{Expected: "array", Value: jsonKind(tok), Offset: dec.InputOffset(), Line: line, Column: column}
+	}
+
+	data := []T{}
+	for dec.More() {
+		if maxElements > 0 && len(data) == maxElements {
+			return nil, &JSONTooManyElementsError{Limit: maxElements}
+		}
+
+		var element T
+		if err := dec.Decode(&element); err != nil {
+			return nil, input.error(err)
+		}
+		data = append(data, element)
+	}
+
+	// The closing bracket
+	if _, err := dec.Token(); err != nil {
+		return nil, input.error(err)
+	}
+
+	err = dec.Decode(&struct{}{})
+	if err != io.EOF {
+		return nil, &JSONMultipleValuesError{}
+	}
+
+	if t.ValidateJSON {
+		if err := t.Validate(data); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// jsonKind returns the kind of JSON value a token of json.Decoder starts.
+func jsonKind(tok json.Token) string {
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '{' {
+			return "object"
+		}
+		return "array"
+	case string:
+		return "string"
+	case float64, json.Number:
+		return "number"
+	case bool:
+		return "bool"
+	}
+	return "null"
+}
@@ -0,0 +1,75 @@
+package toolkit
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type genericItem struct {
+	Foo string `json:"foo"`
+}
+
+func TestReadJSONGeneric(t *testing.T) {
+	tool := Tools{}
+
+	for _, test := range jsonTests {
+		tool.MaxJSONSize = test.maxSize
+		tool.AllowUnknownFields = test.allowUnknown
+
+		req := httptest.NewRequest("POST", "/", strings.NewReader(test.json))
+		rr := httptest.NewRecorder()
+
+		got, err := ReadJSON[genericItem](&tool, rr, req)
+		if test.errorExpected && err == nil {
+			t.Errorf("%s: error expected, but none received\n", test.name)
+		}
+		if !test.errorExpected && err != nil {
+			t.Errorf("%s: expected success, but received error %s\n", test.name, err.Error())
+		}
+		if test.name == "good JSON" && got.Foo != "bar" {
+			t.Errorf("%s: expected foo to be bar, got %q\n", test.name, got.Foo)
+		}
+	}
+}
+
+func TestReadJSONArray(t *testing.T) {
+	tests := []struct {
+		name          string
+		json          string
+		maxElements   int
+		maxSize       int
+		want          int
+		errorExpected bool
+	}{
+		{name: "array", json: `[{"foo": "a"}, {"foo": "b"}]`, maxElements: 5, want: 2},
+		{name: "empty array", json: `[]`, maxElements: 5, want: 0},
+		{name: "no limit", json: `[{"foo": "a"}, {"foo": "b"}, {"foo": "c"}]`, want: 3},
+		{name: "exactly max elements", json: `[{"foo": "a"}, {"foo": "b"}]`, maxElements: 2, want: 2},
+		{name: "too many elements", json: `[{"foo": "a"}, {"foo": "b"}, {"foo": "c"}]`, maxElements: 2, errorExpected: true},
+		{name: "not an array", json: `{"foo": "a"}`, maxElements: 5, errorExpected: true},
+		{name: "incorrect type", json: `[{"foo": 1}]`, maxElements: 5, errorExpected: true},
+		{name: "unknown field", json: `[{"bar": "a"}]`, maxElements: 5, errorExpected: true},
+		{name: "unterminated", json: `[{"foo": "a"}`, maxElements: 5, errorExpected: true},
+		{name: "two values", json: `[][]`, maxElements: 5, errorExpected: true},
+		{name: "empty body", json: ``, maxElements: 5, errorExpected: true},
+		{name: "too large", json: `[{"foo": "a"}, {"foo": "b"}]`, maxSize: 10, errorExpected: true},
+	}
+
+	for _, test := range tests {
+		tool := Tools{MaxJSONSize: test.maxSize}
+		req := httptest.NewRequest("POST", "/", strings.NewReader(test.json))
+		rr := httptest.NewRecorder()
+
+		got, err := ReadJSONArray[genericItem](&tool, rr, req, test.maxElements)
+		if test.errorExpected && err == nil {
+			t.Errorf("%s: error expected, but none received\n", test.name)
+		}
+		if !test.errorExpected && err != nil {
+			t.Errorf("%s: expected success, but received error %s\n", test.name, err.Error())
+		}
+		if !test.errorExpected && len(got) != test.want {
+			t.Errorf("%s: expected %d elements, got %d\n", test.name, test.want, len(got))
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package toolkit
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// maxDecodeDepth limits the nesting of arrays and maps the binary and YAML decoders accept.
+const maxDecodeDepth = 1000
+
+// errDecodeDepth is returned if a document nests deeper than maxDecodeDepth.
+var errDecodeDepth = errors.New("document is nested too deeply")
+
+// msgpackCodec encodes application/msgpack (MessagePack, see https://msgpack.org).
+type msgpackCodec struct{}
+
+func (msgpackCodec) MediaTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+func (msgpackCodec) Encode(w io.Writer, v interface{}) error {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := encodeMsgpack(bw, generic); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (msgpackCodec) Decode(r io.Reader, v interface{}) error {
+	br := bufio.NewReader(r)
+	generic, err := decodeMsgpack(br, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		return errors.New("msgpack: unexpected data after top-level value")
+	}
+	return fromGeneric(generic, v)
+}
+
+// encodeMsgpack writes a value produced by toGeneric.
+func encodeMsgpack(w *bufio.Writer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		return w.WriteByte(0xc0)
+
+	case bool:
+		if v {
+			return w.WriteByte(0xc3)
+		}
+		return w.WriteByte(0xc2)
+
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return encodeMsgpackInt(w, i)
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return writeUint(w, 0xcf, u, 8)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		return writeUint(w, 0xcb, math.Float64bits(f), 8)
+
+	case string:
+		n := len(v)
+		var err error
+		switch {
+		case n < 32:
+			err = w.WriteByte(0xa0 | byte(n))
+		case n <= math.MaxUint8:
+			err = writeUint(w, 0xd9, uint64(n), 1)
+		case n <= math.MaxUint16:
+			err = writeUint(w, 0xda, uint64(n), 2)
+		default:
+			err = writeUint(w, 0xdb, uint64(n), 4)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = w.WriteString(v)
+		return err
+
+	case []interface{}:
+		if err := writeMsgpackLength(w, len(v), 0x90, 0xdc, 0xdd); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := encodeMsgpack(w, item); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case map[string]interface{}:
+		if err := writeMsgpackLength(w, len(v), 0x80, 0xde, 0xdf); err != nil {
+			return err
+		}
+		for _, k := range sortedKeys(v) {
+			if err := encodeMsgpack(w, k); err != nil {
+				return err
+			}
+			if err := encodeMsgpack(w, v[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("msgpack: unsupported type %T", v)
+}
+
+// encodeMsgpackInt writes i in the shortest format.
+func encodeMsgpackInt(w *bufio.Writer, i int64) error {
+	switch {
+	case i >= 0 && i <= math.MaxInt8:
+		return w.WriteByte(byte(i))
+	case i >= -32 && i < 0:
+		return w.WriteByte(byte(i))
+	case i >= 0 && i <= math.MaxUint8:
+		return writeUint(w, 0xcc, uint64(i), 1)
+	case i >= 0 && i <= math.MaxUint16:
+		return writeUint(w, 0xcd, uint64(i), 2)
+	case i >= 0 && i <= math.MaxUint32:
+		return writeUint(w, 0xce, uint64(i), 4)
+	case i >= 0:
+		return writeUint(w, 0xcf, uint64(i), 8)
+	case i >= math.MinInt8:
+		return writeUint(w, 0xd0, uint64(i), 1)
+	case i >= math.MinInt16:
+		return writeUint(w, 0xd1, uint64(i), 2)
+	case i >= math.MinInt32:
+		return writeUint(w, 0xd2, uint64(i), 4)
+	}
+	return writeUint(w, 0xd3, uint64(i), 8)
+}
+
+// writeMsgpackLength writes the header of an array or map with n elements.
+func writeMsgpackLength(w *bufio.Writer, n int, fix, code16, code32 byte) error {
+	switch {
+	case n < 16:
+		return w.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		return writeUint(w, code16, uint64(n), 2)
+	}
+	return writeUint(w, code32, uint64(n), 4)
+}
+
+// writeUint writes the byte code followed by the size lowest bytes of u in big-endian order.
+func writeUint(w *bufio.Writer, code byte, u uint64, size int) error {
+	var buf [9]byte
+	buf[0] = code
+	binary.BigEndian.PutUint64(buf[1:], u)
+	_, err := w.Write(append(buf[:1], buf[9-size:]...))
+	return err
+}
+
+// sortedKeys returns the keys of m in order, so that encoded documents are deterministic.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// decodeMsgpack reads a value. Integers become int64 or uint64, floats float64, binary data []byte
+// and maps map[string]interface{}.
+func decodeMsgpack(r *bufio.Reader, depth int) (interface{}, error) {
+	if depth > maxDecodeDepth {
+		return nil, errDecodeDepth
+	}
+
+	code, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code >= 0xa0 && code <= 0xbf:
+		return readString(r, uint64(code&0x1f))
+	case code >= 0x90 && code <= 0x9f:
+		return decodeMsgpackArray(r, uint64(code&0x0f), depth)
+	case code >= 0x80 && code <= 0x8f:
+		return decodeMsgpackMap(r, uint64(code&0x0f), depth)
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readUint(r, 1<<(code-0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return readBytes(r, n)
+	case 0xca:
+		u, err := readUint(r, 4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := readUint(r, 8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := readUint(r, 1<<(code-0xcc))
+		if u <= math.MaxInt64 {
+			return int64(u), err
+		}
+		return u, err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (code - 0xd0)
+		u, err := readUint(r, size)
+		// Sign-extend the value.
+		shift := 64 - 8*size
+		return int64(u<<shift) >> shift, err
+	case 0xd9, 0xda, 0xdb:
+		n, err := readUint(r, 1<<(code-0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return readString(r, n)
+	case 0xdc, 0xdd:
+		n, err := readUint(r, 2<<(code-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgpackArray(r, n, depth)
+	case 0xde, 0xdf:
+		n, err := readUint(r, 2<<(code-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgpackMap(r, n, depth)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported format 0x%02x", code)
+}
+
+func decodeMsgpackArray(r *bufio.Reader, n uint64, depth int) (interface{}, error) {
+	list := make([]interface{}, 0, capHint(n))
+	for i := uint64(0); i < n; i++ {
+		item, err := decodeMsgpack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+func decodeMsgpackMap(r *bufio.Reader, n uint64, depth int) (interface{}, error) {
+	m := make(map[string]interface{}, capHint(n))
+	for i := uint64(0); i < n; i++ {
+		key, err := decodeMsgpack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		k, err := mapKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if m[k], err = decodeMsgpack(r, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// mapKey converts a decoded map key to a string. Integer keys are allowed as JSON objects can't have
+// other keys anyway.
+func mapKey(key interface{}) (string, error) {
+	switch k := key.(type) {
+	case string:
+		return k, nil
+	case int64:
+		return strconv.FormatInt(k, 10), nil
+	case uint64:
+		return strconv.FormatUint(k, 10), nil
+	}
+	return "", fmt.Errorf("unsupported map key of type %T", key)
+}
+
+// capHint limits preallocation for untrusted lengths.
+func capHint(n uint64) int {
+	if n > 1024 {
+		return 1024
+	}
+	return int(n)
+}
+
+// readUint reads a big-endian unsigned integer of size bytes.
+func readUint(r io.Reader, size int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[8-size:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// readBytes reads n bytes without trusting n for the allocation.
+func readBytes(r io.Reader, n uint64) ([]byte, error) {
+	if n > math.MaxInt64 {
+		return nil, errors.New("length out of range")
+	}
+	buf := make([]byte, 0, capHint(n))
+	for uint64(len(buf)) < n {
+		chunk := n - uint64(len(buf))
+		if chunk > 64*1024 {
+			chunk = 64 * 1024
+		}
+		start := len(buf)
+		buf = append(buf, make([]byte, chunk)...)
+		if _, err := io.ReadFull(r, buf[start:]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+	return buf, nil
+}
+
+// readString reads n bytes as string.
+func readString(r io.Reader, n uint64) (interface{}, error) {
+	b, err := readBytes(r, n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// unexpectedEOF turns io.EOF into io.ErrUnexpectedEOF, since a document must not end within a value.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
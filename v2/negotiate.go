@@ -0,0 +1,100 @@
+package toolkit
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// qualityValue is an element of an Accept-* header with its weight.
+type qualityValue struct {
+	value string
+	q     float64
+}
+
+// parseQualityList parses a header like "gzip;q=0.8, br" into its elements, ordered by descending
+// weight. Elements of equal weight keep their order. Values are lower cased.
+func parseQualityList(header string) []qualityValue {
+	var list []qualityValue
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		qv := qualityValue{q: 1}
+		params := strings.Split(part, ";")
+		qv.value = strings.ToLower(strings.TrimSpace(params[0]))
+		for _, param := range params[1:] {
+			k, v, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.EqualFold(strings.TrimSpace(k), "q") {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && q >= 0 && q <= 1 {
+					qv.q = q
+				}
+			}
+		}
+		list = append(list, qv)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].q > list[j].q
+	})
+	return list
+}
+
+// encodingQuality returns the weight the Accept-Encoding header gives coding. An explicit entry takes
+// precedence over "*". Without a matching entry a coding is not acceptable, except for identity.
+func encodingQuality(acceptEncoding, coding string) float64 {
+	q, wildcard := -1.0, -1.0
+	for _, qv := range parseQualityList(acceptEncoding) {
+		switch {
+		case qv.value == coding || (coding == "gzip" && qv.value == "x-gzip"):
+			if qv.q > q {
+				q = qv.q
+			}
+		case qv.value == "*":
+			wildcard = qv.q
+		}
+	}
+	switch {
+	case q >= 0:
+		return q
+	case wildcard >= 0:
+		return wildcard
+	case coding == "identity":
+		return 1
+	}
+	return 0
+}
+
+// compressibleTypes are the media types that benefit from compression. Types with a +json or +xml
+// suffix and all text types are compressible, too.
+var compressibleTypes = []string{
+	"application/javascript",
+	"application/json",
+	"application/wasm",
+	"application/xml",
+	"image/svg+xml",
+	"image/x-icon",
+	"font/otf",
+	"font/ttf",
+}
+
+// isCompressible reports whether content of the media type contentType (parameters are ignored)
+// benefits from compression.
+func isCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	for _, ct := range compressibleTypes {
+		if mediaType == ct {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,302 @@
+package toolkit
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The query parameters of paginated requests.
+const (
+	PageParam   = "page"
+	SizeParam   = "size"
+	CursorParam = "cursor"
+)
+
+// defaultPageSize and defaultMaxPageSize are used if DefaultPageSize and MaxPageSize are not set.
+const (
+	defaultPageSize    = 20
+	defaultMaxPageSize = 100
+)
+
+// ErrInvalidCursor is returned for cursors which are malformed, tampered with or signed by an unknown key.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page is the page of a listing requested by a client, either by number or by cursor.
+type Page struct {
+	// Number is the 1-based page number, or 0 if the page is requested by cursor.
+	Number int
+	// Size is the number of items per page.
+	Size int
+
+	cursor []byte
+}
+
+// Offset returns the number of items before the page, for page numbers.
+func (p Page) Offset() int {
+	if p.Number < 1 {
+		return 0
+	}
+	return (p.Number - 1) * p.Size
+}
+
+// HasCursor reports whether the page was requested by cursor. Without page and cursor parameters, the
+// first page is requested, which has neither.
+func (p Page) HasCursor() bool {
+	return p.cursor != nil
+}
+
+// Cursor decodes the value of the cursor into v, which EncodeCursor encoded.
+func (p Page) Cursor(v interface{}) error {
+	if p.cursor == nil {
+		return errors.New("page has no cursor")
+	}
+	return json.Unmarshal(p.cursor, v)
+}
+
+// PageParamError is returned by ReadPage for an invalid pagination parameter.
+type PageParamError struct {
+	Param string
+	Value string
+	Err   error
+}
+
+// Error implements the error interface.
+func (e *PageParamError) Error() string {
+	return fmt.Sprintf("invalid %s parameter %q: %v", e.Param, e.Value, e.Err)
+}
+
+// Unwrap returns the cause, e.g. ErrInvalidCursor.
+func (e *PageParamError) Unwrap() error { return e.Err }
+
+// StatusCode returns http.StatusBadRequest.
+func (e *PageParamError) StatusCode() int { return http.StatusBadRequest }
+
+// ReadPage reads the page requested by the query parameters page and size, or cursor and size. The size
+// defaults to DefaultPageSize and is capped at MaxPageSize. Cursors are verified with CursorSigner.
+// Invalid parameters are returned as *PageParamError.
+func (t *Tools) ReadPage(r *http.Request) (Page, error) {
+	q := r.URL.Query()
+	page := Page{Number: 1, Size: t.pageSize()}
+
+	if value := q.Get(SizeParam); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 {
+			return Page{}, &PageParamError{Param: SizeParam, Value: value, Err: errors.New("must be a positive integer")}
+		}
+		if max := t.maxPageSize(); size > max {
+			size = max
+		}
+		page.Size = size
+	}
+
+	number, cursor := q.Get(PageParam), q.Get(CursorParam)
+	switch {
+	case number != "" && cursor != "":
+		return Page{}, &PageParamError{Param: CursorParam, Value: cursor, Err: errors.New("can't be combined with page")}
+	case number != "":
+		n, err := strconv.Atoi(number)
+		// The offset must not overflow.
+		if err != nil || n < 1 || n > math.MaxInt32/page.Size {
+			return Page{}, &PageParamError{Param: PageParam, Value: number, Err: errors.New("must be a positive integer")}
+		}
+		page.Number = n
+	case cursor != "":
+		payload, err := t.decodeCursor(cursor)
+		if err != nil {
+			return Page{}, &PageParamError{Param: CursorParam, Value: cursor, Err: err}
+		}
+		page.Number = 0
+		page.cursor = payload
+	}
+	return page, nil
+}
+
+// pageSize returns DefaultPageSize or its default, capped at the maximum.
+func (t *Tools) pageSize() int {
+	size := t.DefaultPageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	if max := t.maxPageSize(); size > max {
+		size = max
+	}
+	return size
+}
+
+// maxPageSize returns MaxPageSize or its default.
+func (t *Tools) maxPageSize() int {
+	if t.MaxPageSize > 0 {
+		return t.MaxPageSize
+	}
+	return defaultMaxPageSize
+}
+
+// EncodeCursor returns an opaque cursor for v, typically the sort key of the last item of a page. v is
+// encoded as JSON and signed with CursorSigner, so clients can't forge cursors; it is not encrypted.
+func (t *Tools) EncodeCursor(v interface{}) (string, error) {
+	if t.CursorSigner == nil {
+		return "", errors.New("cursors need a CursorSigner")
+	}
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return t.CursorSigner.SignCursor(payload)
+}
+
+// decodeCursor verifies a cursor and returns its JSON payload.
+func (t *Tools) decodeCursor(cursor string) ([]byte, error) {
+	if t.CursorSigner == nil {
+		return nil, ErrInvalidCursor
+	}
+	return t.CursorSigner.VerifyCursor(cursor)
+}
+
+// SignCursor returns payload and its signature as a URL-safe string.
+func (s *URLSigner) SignCursor(payload []byte) (string, error) {
+	key, err := s.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString([]byte(key.ID)) + "." +
+		base64.RawURLEncoding.EncodeToString(signParts(key, "cursor", encoded)), nil
+}
+
+// VerifyCursor checks the signature of a cursor created by SignCursor and returns its payload. The error
+// is ErrInvalidCursor.
+func (s *URLSigner) VerifyCursor(cursor string) ([]byte, error) {
+	parts := strings.Split(cursor, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCursor
+	}
+	id, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	key, ok := s.key(string(id))
+	if !ok || !hmac.Equal(sig, signParts(key, "cursor", parts[0])) {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return payload, nil
+}
+
+// PageResult describes the page of items written by WritePage.
+type PageResult struct {
+	// Total is the number of items of all pages, if known. It is required for a link to the last page.
+	Total *int64
+	// HasMore reports whether there are items after a page requested by number if Total is unknown.
+	HasMore bool
+	// NextCursor and PrevCursor are the cursors returned by EncodeCursor for the pages after and before
+	// the page in a listing paginated by cursor, or empty at its end and its start.
+	NextCursor string
+	PrevCursor string
+}
+
+// PageInfo is the pagination metadata of a PageResponse. The links are relative to the request URL.
+type PageInfo struct {
+	Page       int    `json:"page,omitempty"`
+	Size       int    `json:"size"`
+	Total      *int64 `json:"total,omitempty"`
+	TotalPages *int64 `json:"total_pages,omitempty"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+}
+
+// PageResponse is the envelope WritePage writes around the items of a page.
+type PageResponse struct {
+	Data       interface{} `json:"data"`
+	Pagination PageInfo    `json:"pagination"`
+}
+
+// WritePage writes items, the items of page, as PageResponse with links to the next and previous page,
+// which are also sent as Link header (RFC 8288) together with the first and, if Total is known, the last
+// page. The links keep the other query parameters of r. A page not read by ReadPage gets its defaults:
+// a Size below 1 is DefaultPageSize, a Number below 1 without cursor the first page.
+func (t *Tools) WritePage(w http.ResponseWriter, r *http.Request, status int, page Page, items interface{}, result PageResult, headers ...http.Header) error {
+	if page.Size < 1 {
+		page.Size = t.pageSize()
+	}
+	if page.Number < 1 && !page.HasCursor() {
+		page.Number = 1
+	}
+	info := PageInfo{Page: page.Number, Size: page.Size, Total: result.Total}
+
+	var links []string
+	addLink := func(rel string, params map[string]string) string {
+		link := pageURL(r.URL, params)
+		links = append(links, fmt.Sprintf("<%s>; rel=%q", link, rel))
+		return link
+	}
+
+	addLink("first", map[string]string{PageParam: "", CursorParam: ""})
+	if page.HasCursor() {
+		if result.PrevCursor != "" {
+			info.Prev = addLink("prev", map[string]string{CursorParam: result.PrevCursor})
+		}
+		if result.NextCursor != "" {
+			info.Next = addLink("next", map[string]string{CursorParam: result.NextCursor})
+		}
+	} else {
+		hasNext := result.HasMore
+		if result.Total != nil {
+			totalPages := (*result.Total + int64(page.Size) - 1) / int64(page.Size)
+			info.TotalPages = &totalPages
+			hasNext = int64(page.Number) < totalPages
+		}
+
+		if page.Number > 1 {
+			info.Prev = addLink("prev", map[string]string{PageParam: strconv.Itoa(page.Number - 1), CursorParam: ""})
+		}
+		if result.NextCursor != "" {
+			info.Next = addLink("next", map[string]string{CursorParam: result.NextCursor, PageParam: ""})
+		} else if hasNext {
+			info.Next = addLink("next", map[string]string{PageParam: strconv.Itoa(page.Number + 1), CursorParam: ""})
+		}
+		if info.TotalPages != nil && *info.TotalPages > 0 {
+			addLink("last", map[string]string{PageParam: strconv.FormatInt(*info.TotalPages, 10), CursorParam: ""})
+		}
+	}
+
+	if len(headers) == 0 {
+		headers = []http.Header{{}}
+	} else {
+		headers = []http.Header{headers[0].Clone()}
+	}
+	headers[0].Set("Link", strings.Join(links, ", "))
+
+	return t.writeJSON(w, r, status, PageResponse{Data: items, Pagination: info}, headers...)
+}
+
+// pageURL returns the path and query of u with the query parameters params set, or removed if empty.
+func pageURL(u *url.URL, params map[string]string) string {
+	q := u.Query()
+	for k, v := range params {
+		if v == "" {
+			q.Del(k)
+		} else {
+			q.Set(k, v)
+		}
+	}
+
+	link := url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: q.Encode()}
+	return link.String()
+}
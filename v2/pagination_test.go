@@ -0,0 +1,220 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadPage(t *testing.T) {
+	signer, _ := NewURLSigner(SigningKey{ID: "k1", Secret: []byte("secret")})
+	tool := Tools{MaxPageSize: 50, CursorSigner: signer}
+	cursor, err := tool.EncodeCursor(map[string]int{"after": 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		number int
+		size   int
+		cursor bool
+		err    bool
+	}{
+		{name: "defaults", query: "", number: 1, size: 20},
+		{name: "page and size", query: "page=3&size=10", number: 3, size: 10},
+		{name: "size capped", query: "size=500", number: 1, size: 50},
+		{name: "cursor", query: "cursor=" + cursor + "&size=5", size: 5, cursor: true},
+		{name: "zero page", query: "page=0", err: true},
+		{name: "negative size", query: "size=-1", err: true},
+		{name: "not a number", query: "page=two", err: true},
+		{name: "page overflow", query: "page=9999999999", err: true},
+		{name: "page and cursor", query: "page=2&cursor=" + cursor, err: true},
+		{name: "tampered cursor", query: "cursor=eyJhZnRlciI6NDN9" + cursor[strings.Index(cursor, "."):], err: true},
+		{name: "garbage cursor", query: "cursor=abc", err: true},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/items?"+test.query, nil)
+		page, err := tool.ReadPage(req)
+		if test.err {
+			var paramErr *PageParamError
+			if !errors.As(err, &paramErr) || paramErr.StatusCode() != http.StatusBadRequest {
+				t.Errorf("%s: expected *PageParamError, got %v\n", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v\n", test.name, err)
+			continue
+		}
+		if page.Number != test.number || page.Size != test.size || page.HasCursor() != test.cursor {
+			t.Errorf("%s: unexpected page %+v\n", test.name, page)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/items?page=3&size=10", nil)
+	if page, _ := tool.ReadPage(req); page.Offset() != 20 {
+		t.Errorf("expected offset 20, got %d\n", page.Offset())
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	old := SigningKey{ID: "old", Secret: []byte("old secret")}
+	signer, _ := NewURLSigner(old)
+	tool := Tools{CursorSigner: signer}
+
+	type position struct {
+		ID      int    `json:"id"`
+		Created string `json:"created"`
+	}
+	cursor, err := tool.EncodeCursor(position{ID: 7, Created: "2024-01-02"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cursors stay valid while the key is active.
+	_ = signer.Rotate(SigningKey{ID: "new", Secret: []byte("new secret")})
+	page, err := tool.ReadPage(httptest.NewRequest(http.MethodGet, "/?cursor="+cursor, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pos position
+	if err := page.Cursor(&pos); err != nil || pos.ID != 7 || pos.Created != "2024-01-02" {
+		t.Errorf("unexpected cursor %+v, %v\n", pos, err)
+	}
+
+	_ = signer.Retire("old")
+	_, err = tool.ReadPage(httptest.NewRequest(http.MethodGet, "/?cursor="+cursor, nil))
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor, got %v\n", err)
+	}
+
+	if _, err := (&Tools{}).EncodeCursor(pos); err == nil {
+		t.Error("expected an error without CursorSigner")
+	}
+}
+
+func TestWritePage(t *testing.T) {
+	signer, _ := NewURLSigner(SigningKey{ID: "k1", Secret: []byte("secret")})
+	tool := Tools{CursorSigner: signer}
+	total := int64(45)
+	next, _ := tool.EncodeCursor(20)
+
+	tests := []struct {
+		name   string
+		query  string
+		result PageResult
+		links  []string
+		info   string
+	}{
+		{
+			name:   "middle page",
+			query:  "page=2&size=10&sort=name",
+			result: PageResult{Total: &total},
+			links: []string{
+				`</items?size=10&sort=name>; rel="first"`,
+				`</items?page=1&size=10&sort=name>; rel="prev"`,
+				`</items?page=3&size=10&sort=name>; rel="next"`,
+				`</items?page=5&size=10&sort=name>; rel="last"`,
+			},
+			info: `{"page":2,"size":10,"total":45,"total_pages":5,"next":"/items?page=3&size=10&sort=name","prev":"/items?page=1&size=10&sort=name"}`,
+		},
+		{
+			name:   "last page",
+			query:  "page=5&size=10",
+			result: PageResult{Total: &total},
+			links: []string{
+				`</items?size=10>; rel="first"`,
+				`</items?page=4&size=10>; rel="prev"`,
+				`</items?page=5&size=10>; rel="last"`,
+			},
+			info: `{"page":5,"size":10,"total":45,"total_pages":5,"prev":"/items?page=4&size=10"}`,
+		},
+		{
+			name:   "unknown total",
+			query:  "",
+			result: PageResult{HasMore: true},
+			links:  []string{`</items>; rel="first"`, `</items?page=2>; rel="next"`},
+			info:   `{"page":1,"size":20,"next":"/items?page=2"}`,
+		},
+		{
+			name:   "first page by cursor",
+			query:  "size=20",
+			result: PageResult{NextCursor: next},
+			links:  []string{`</items?size=20>; rel="first"`, `</items?cursor=` + next + `&size=20>; rel="next"`},
+			info:   `{"page":1,"size":20,"next":"/items?cursor=` + next + `&size=20"}`,
+		},
+		{
+			name:   "by cursor",
+			query:  "cursor=" + next,
+			result: PageResult{PrevCursor: "p"},
+			links:  []string{`</items>; rel="first"`, `</items?cursor=p>; rel="prev"`},
+			info:   `{"size":20,"prev":"/items?cursor=p"}`,
+		},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/items?"+test.query, nil)
+		page, err := tool.ReadPage(req)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		rr := httptest.NewRecorder()
+		if err := tool.WritePage(rr, req, http.StatusOK, page, []string{"a", "b"}, test.result); err != nil {
+			t.Errorf("%s: unexpected error: %v\n", test.name, err)
+			continue
+		}
+
+		if got, want := rr.Header().Get("Link"), strings.Join(test.links, ", "); got != want {
+			t.Errorf("%s: expected Link %s, got %s\n", test.name, want, got)
+		}
+		var resp struct {
+			Data       []string        `json:"data"`
+			Pagination json.RawMessage `json:"pagination"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Errorf("%s: invalid body: %v\n", test.name, err)
+			continue
+		}
+		if len(resp.Data) != 2 || !sameJSON(t, resp.Pagination, []byte(test.info)) {
+			t.Errorf("%s: expected pagination %s, got %s\n", test.name, test.info, resp.Pagination)
+		}
+	}
+}
+
+func TestWritePageZeroPage(t *testing.T) {
+	var tool Tools
+	total := int64(45)
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+
+	for _, page := range []Page{{}, {Number: 2}} {
+		rr := httptest.NewRecorder()
+		if err := tool.WritePage(rr, req, http.StatusOK, page, []string{}, PageResult{Total: &total}); err != nil {
+			t.Errorf("%+v: unexpected error: %v\n", page, err)
+			continue
+		}
+
+		var resp PageResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Errorf("%+v: invalid body: %v\n", page, err)
+			continue
+		}
+		info := resp.Pagination
+		if info.Size != defaultPageSize || info.TotalPages == nil || *info.TotalPages != 3 {
+			t.Errorf("%+v: expected the default size and 3 pages, got %+v\n", page, info)
+		}
+		want := page.Number
+		if want == 0 {
+			want = 1
+		}
+		if info.Page != want {
+			t.Errorf("%+v: expected page %d, got %d\n", page, want, info.Page)
+		}
+	}
+}
@@ -0,0 +1,524 @@
+package toolkit
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The media types of patch documents.
+const (
+	JSONPatchContentType  = "application/json-patch+json"
+	MergePatchContentType = "application/merge-patch+json"
+)
+
+// PatchOperation is an operation of a JSON Patch (RFC 6902). Value is nil if the operation has no value
+// member; a JSON null value is kept as "null".
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch is a JSON Patch document (RFC 6902), a list of operations applied in order.
+type JSONPatch []PatchOperation
+
+// InvalidPatchError is returned for a patch document that is malformed, e.g. an operation without path.
+// Index is the index of the offending operation, or -1 if the document as a whole is invalid.
+type InvalidPatchError struct {
+	Index   int
+	Message string
+}
+
+// Error implements the error interface.
+func (e *InvalidPatchError) Error() string {
+	if e.Index < 0 {
+		return "invalid patch: " + e.Message
+	}
+	return fmt.Sprintf("invalid patch operation %d: %s", e.Index, e.Message)
+}
+
+// StatusCode returns http.StatusBadRequest.
+func (e *InvalidPatchError) StatusCode() int { return http.StatusBadRequest }
+
+// PatchPathError is returned if an operation of a JSON Patch refers to a location that doesn't exist in
+// the document, e.g. the path of a remove operation.
+type PatchPathError struct {
+	Index  int
+	Op     string
+	Path   string
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *PatchPathError) Error() string {
+	return fmt.Sprintf("patch operation %d (%s) failed at %q: %s", e.Index, e.Op, e.Path, e.Reason)
+}
+
+// StatusCode returns http.StatusConflict, as the patch can't be applied to the current state of the
+// resource.
+func (e *PatchPathError) StatusCode() int { return http.StatusConflict }
+
+// PatchTestError is returned if a test operation of a JSON Patch fails.
+type PatchTestError struct {
+	Index int
+	Path  string
+}
+
+// Error implements the error interface.
+func (e *PatchTestError) Error() string {
+	return fmt.Sprintf("patch operation %d (test) failed: value at %q is not the expected one", e.Index, e.Path)
+}
+
+// StatusCode returns http.StatusConflict.
+func (e *PatchTestError) StatusCode() int { return http.StatusConflict }
+
+// ParseJSONPatch parses and checks a JSON Patch document. Members of operations that are not defined
+// for them are ignored.
+func ParseJSONPatch(data []byte) (JSONPatch, error) {
+	var patch JSONPatch
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, &InvalidPatchError{Index: -1, Message: "must be an array of operations"}
+	}
+	if err := patch.check(); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+// check verifies that the operations have the members they need.
+func (p JSONPatch) check() error {
+	for i, op := range p {
+		invalid := func(format string, args ...interface{}) error {
+			return &InvalidPatchError{Index: i, Message: fmt.Sprintf(format, args...)}
+		}
+
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return invalid("%s requires a value", op.Op)
+			}
+		case "remove":
+		case "move", "copy":
+			if _, err := parseJSONPointer(op.From); err != nil {
+				return invalid("invalid from %q", op.From)
+			}
+		case "":
+			return invalid("op is missing")
+		default:
+			return invalid("unknown op %q", op.Op)
+		}
+		if _, err := parseJSONPointer(op.Path); err != nil {
+			return invalid("invalid path %q", op.Path)
+		}
+		if op.Op == "move" && strings.HasPrefix(op.Path, op.From+"/") {
+			return invalid("can't move %q into itself", op.From)
+		}
+	}
+	return nil
+}
+
+// Apply applies the patch to the JSON document doc and returns the patched document. The patch is
+// applied atomically: if an operation fails, the error is returned and no document.
+func (p JSONPatch) Apply(doc []byte) ([]byte, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	v, err := decodeGenericJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range p {
+		if v, err = applyPatchOperation(v, op); err != nil {
+			var pathErr *PatchPathError
+			if errors.As(err, &pathErr) {
+				pathErr.Index = i
+			}
+			var testErr *PatchTestError
+			if errors.As(err, &testErr) {
+				testErr.Index = i
+			}
+			var invalidErr *InvalidPatchError
+			if errors.As(err, &invalidErr) {
+				invalidErr.Index = i
+			}
+			return nil, err
+		}
+	}
+	return json.Marshal(v)
+}
+
+// applyPatchOperation applies op to doc and returns the new document.
+func applyPatchOperation(doc interface{}, op PatchOperation) (interface{}, error) {
+	var value interface{}
+	if op.Value != nil {
+		var err error
+		if value, err = decodeGenericJSON(op.Value); err != nil {
+			return nil, &InvalidPatchError{Message: "invalid value"}
+		}
+	}
+	path, _ := parseJSONPointer(op.Path)
+	failed := func(reason string) error {
+		return &PatchPathError{Op: op.Op, Path: op.Path, Reason: reason}
+	}
+
+	switch op.Op {
+	case "add":
+		return addJSONValue(doc, path, value, failed)
+	case "remove":
+		if len(path) == 0 {
+			return nil, failed("the document can't be removed")
+		}
+		doc, _, err := removeJSONValue(doc, path, failed)
+		return doc, err
+	case "replace":
+		doc, _, err := removeJSONValue(doc, path, failed)
+		if err != nil {
+			return nil, err
+		}
+		return addJSONValue(doc, path, value, failed)
+	case "move":
+		from, _ := parseJSONPointer(op.From)
+		doc, moved, err := removeJSONValue(doc, from, func(reason string) error {
+			return &PatchPathError{Op: op.Op, Path: op.From, Reason: reason}
+		})
+		if err != nil {
+			return nil, err
+		}
+		return addJSONValue(doc, path, moved, failed)
+	case "copy":
+		copied, ok := resolveJSONPointer(doc, op.From)
+		if !ok {
+			return nil, &PatchPathError{Op: op.Op, Path: op.From, Reason: "value not found"}
+		}
+		return addJSONValue(doc, path, copyJSONValue(copied), failed)
+	default: // test
+		current, ok := resolveJSONPointer(doc, op.Path)
+		if !ok || !jsonEqual(current, value) {
+			return nil, &PatchTestError{Path: op.Path}
+		}
+		return doc, nil
+	}
+}
+
+// parseJSONPointer splits a JSON Pointer into its unescaped reference tokens.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = unescapeJSONPointer(token)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses the array index token for an array of length n. Only digits without leading
+// zeros are allowed.
+func arrayIndex(token string, n int) (int, bool) {
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, false
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i >= n {
+		return 0, false
+	}
+	return i, true
+}
+
+// updateJSONContainer calls fn with the container holding the value at path and the last token of
+// path, and stores the container returned by fn in its place. It returns the new document.
+func updateJSONContainer(doc interface{}, path []string, fn func(container interface{}, token string) (interface{}, error), failed func(reason string) error) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	var err error
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		child, ok := container[path[0]]
+		if !ok {
+			return nil, failed(fmt.Sprintf("member %q not found", path[0]))
+		}
+		if container[path[0]], err = updateJSONContainer(child, path[1:], fn, failed); err != nil {
+			return nil, err
+		}
+		return container, nil
+	case []interface{}:
+		i, ok := arrayIndex(path[0], len(container))
+		if !ok {
+			return nil, failed(fmt.Sprintf("index %q out of range", path[0]))
+		}
+		if container[i], err = updateJSONContainer(container[i], path[1:], fn, failed); err != nil {
+			return nil, err
+		}
+		return container, nil
+	default:
+		return nil, failed("value is neither an object nor an array")
+	}
+}
+
+// addJSONValue adds value at path, replacing an existing member of an object or inserting into an
+// array. "-" appends to an array.
+func addJSONValue(doc interface{}, path []string, value interface{}, failed func(reason string) error) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return updateJSONContainer(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch container := container.(type) {
+		case map[string]interface{}:
+			container[token] = value
+			return container, nil
+		case []interface{}:
+			if token == "-" {
+				return append(container, value), nil
+			}
+			i, ok := arrayIndex(token, len(container)+1)
+			if !ok {
+				return nil, failed(fmt.Sprintf("index %q out of range", token))
+			}
+			container = append(container, nil)
+			copy(container[i+1:], container[i:])
+			container[i] = value
+			return container, nil
+		default:
+			return nil, failed("parent is neither an object nor an array")
+		}
+	}, failed)
+}
+
+// removeJSONValue removes the value at path and returns the new document and the removed value. An
+// empty path removes the whole document, for replace and move.
+func removeJSONValue(doc interface{}, path []string, failed func(reason string) error) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+
+	var removed interface{}
+	doc, err := updateJSONContainer(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch container := container.(type) {
+		case map[string]interface{}:
+			v, ok := container[token]
+			if !ok {
+				return nil, failed(fmt.Sprintf("member %q not found", token))
+			}
+			removed = v
+			delete(container, token)
+			return container, nil
+		case []interface{}:
+			i, ok := arrayIndex(token, len(container))
+			if !ok {
+				return nil, failed(fmt.Sprintf("index %q out of range", token))
+			}
+			removed = container[i]
+			return append(container[:i], container[i+1:]...), nil
+		default:
+			return nil, failed("parent is neither an object nor an array")
+		}
+	}, failed)
+	return doc, removed, err
+}
+
+// copyJSONValue returns a deep copy of a generic JSON value.
+func copyJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = copyJSONValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = copyJSONValue(e)
+		}
+		return s
+	}
+	return v
+}
+
+// MergePatch applies the JSON Merge Patch (RFC 7396) patch to the JSON document doc: members of patch
+// objects replace the members of doc recursively, null members remove them and any other patch replaces
+// doc as a whole.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	p, err := decodeGenericJSON(patch)
+	if err != nil {
+		return nil, &InvalidPatchError{Index: -1, Message: err.Error()}
+	}
+	v, err := decodeGenericJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeJSONValue(v, p))
+}
+
+func mergeJSONValue(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{}, len(p))
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergeJSONValue(t[k], v)
+		}
+	}
+	return t
+}
+
+// readJSONDocument reads a single JSON value from the body of r, limited to MaxJSONSize like ReadJSON.
+func (t *Tools) readJSONDocument(w http.ResponseWriter, r *http.Request) (json.RawMessage, error) {
+	dec, input, err := t.jsonDecoder(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return nil, input.error(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return nil, &JSONMultipleValuesError{}
+	}
+	return raw, nil
+}
+
+// ReadJSONPatch reads a JSON Patch document from the body of r. MaxJSONSize and the decompression of
+// bodies apply like for ReadJSON.
+func (t *Tools) ReadJSONPatch(w http.ResponseWriter, r *http.Request) (JSONPatch, error) {
+	raw, err := t.readJSONDocument(w, r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJSONPatch(raw)
+}
+
+// ReadMergePatch reads a JSON Merge Patch document from the body of r, like ReadJSONPatch.
+func (t *Tools) ReadMergePatch(w http.ResponseWriter, r *http.Request) (json.RawMessage, error) {
+	return t.readJSONDocument(w, r)
+}
+
+// ApplyJSONPatch applies patch to v, which must be a non-nil pointer. v is marshalled to JSON, patched
+// and decoded into a copy of *v, which replaces *v only if all steps succeed. Fields the JSON doesn't
+// contain, unexported ones and those tagged json:"-" like password hashes, keep their values in v and
+// in nested structs, but not behind pointers, in maps or in slices. The result is checked like a body
+// read by ReadJSON: against the schema registered for v, for unknown fields unless AllowUnknownFields
+// is set and by Validate if ValidateJSON is set.
+func (t *Tools) ApplyJSONPatch(patch JSONPatch, v interface{}) error {
+	return t.patchValue(v, patch.Apply)
+}
+
+// ApplyMergePatch applies the JSON Merge Patch patch to v like ApplyJSONPatch.
+func (t *Tools) ApplyMergePatch(patch []byte, v interface{}) error {
+	return t.patchValue(v, func(doc []byte) ([]byte, error) {
+		return MergePatch(doc, patch)
+	})
+}
+
+// patchValue patches the JSON encoding of v with apply and decodes the result into v.
+func (t *Tools) patchValue(v interface{}, apply func(doc []byte) ([]byte, error)) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("patch target must be a non-nil pointer")
+	}
+
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	patched, err := apply(doc)
+	if err != nil {
+		return err
+	}
+
+	if schema, ok := t.Schemas.Lookup(v); ok {
+		if err := schema.ValidateJSON(patched); err != nil {
+			return err
+		}
+	}
+	// Decoding into the copy keeps the fields that aren't encoded; the others are reset, so that
+	// members removed by the patch are removed from the result.
+	result := reflect.New(rv.Elem().Type())
+	result.Elem().Set(rv.Elem())
+	clearJSONFields(result.Elem())
+	if err := t.decodeJSONValue(patched, result.Interface()); err != nil {
+		return err
+	}
+	rv.Elem().Set(result.Elem())
+	return nil
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// clearJSONFields resets the values of v that are encoded as JSON. Structs are cleared field by field,
+// unless they decode themselves, so that their unexported and json:"-" fields are kept.
+func clearJSONFields(v reflect.Value) {
+	if v.Kind() != reflect.Struct || reflect.PtrTo(v.Type()).Implements(jsonUnmarshalerType) ||
+		reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) {
+		if v.CanSet() {
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Tag.Get("json") == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		clearJSONFields(v.Field(i))
+	}
+}
+
+// acceptPatch lists the patch formats PatchJSON accepts.
+var acceptPatch = []string{JSONPatchContentType, MergePatchContentType}
+
+// PatchJSON reads a patch from the body of r and applies it to v, for PATCH handlers. The format is
+// chosen by the Content-Type of the request, application/json-patch+json or
+// application/merge-patch+json; for other types an *UnsupportedMediaTypeError is returned and the
+// Accept-Patch header (RFC 5789) is set. Failed test operations result in a *PatchTestError,
+// operations on locations that don't exist in a *PatchPathError.
+func (t *Tools) PatchJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != JSONPatchContentType && mediaType != MergePatchContentType) {
+		w.Header().Set("Accept-Patch", strings.Join(acceptPatch, ", "))
+		return &UnsupportedMediaTypeError{ContentType: contentType, Supported: acceptPatch}
+	}
+
+	if mediaType == JSONPatchContentType {
+		patch, err := t.ReadJSONPatch(w, r)
+		if err != nil {
+			return err
+		}
+		return t.ApplyJSONPatch(patch, v)
+	}
+
+	patch, err := t.ReadMergePatch(w, r)
+	if err != nil {
+		return err
+	}
+	return t.ApplyMergePatch(patch, v)
+}
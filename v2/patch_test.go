@@ -0,0 +1,276 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sameJSON reports whether a and b are equal JSON documents.
+func sameJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	va, err := decodeGenericJSON(a)
+	if err != nil {
+		t.Fatalf("invalid JSON %s: %v", a, err)
+	}
+	vb, err := decodeGenericJSON(b)
+	if err != nil {
+		t.Fatalf("invalid JSON %s: %v", b, err)
+	}
+	return jsonEqual(va, vb)
+}
+
+func TestJSONPatchApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+		err   error
+	}{
+		// Examples of RFC 6902, appendix A.
+		{name: "add member", doc: `{"foo": "bar"}`, patch: `[{"op": "add", "path": "/baz", "value": "qux"}]`, want: `{"baz": "qux", "foo": "bar"}`},
+		{name: "add element", doc: `{"foo": ["bar", "baz"]}`, patch: `[{"op": "add", "path": "/foo/1", "value": "qux"}]`, want: `{"foo": ["bar", "qux", "baz"]}`},
+		{name: "remove member", doc: `{"baz": "qux", "foo": "bar"}`, patch: `[{"op": "remove", "path": "/baz"}]`, want: `{"foo": "bar"}`},
+		{name: "remove element", doc: `{"foo": ["bar", "qux", "baz"]}`, patch: `[{"op": "remove", "path": "/foo/1"}]`, want: `{"foo": ["bar", "baz"]}`},
+		{name: "replace", doc: `{"baz": "qux", "foo": "bar"}`, patch: `[{"op": "replace", "path": "/baz", "value": "boo"}]`, want: `{"baz": "boo", "foo": "bar"}`},
+		{name: "move member", doc: `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`, patch: `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`, want: `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`},
+		{name: "move element", doc: `{"foo": ["all", "grass", "cows", "eat"]}`, patch: `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`, want: `{"foo": ["all", "cows", "eat", "grass"]}`},
+		{name: "test", doc: `{"baz": "qux", "foo": ["a", 2, "c"]}`, patch: `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2.0}]`, want: `{"baz": "qux", "foo": ["a", 2, "c"]}`},
+		{name: "test fails", doc: `{"baz": "qux"}`, patch: `[{"op": "test", "path": "/baz", "value": "bar"}]`, err: &PatchTestError{}},
+		{name: "add nested", doc: `{"foo": "bar"}`, patch: `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`, want: `{"foo": "bar", "child": {"grandchild": {}}}`},
+		{name: "unknown members", doc: `{"foo": "bar"}`, patch: `[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`, want: `{"foo": "bar", "baz": "qux"}`},
+		{name: "missing parent", doc: `{"foo": "bar"}`, patch: `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`, err: &PatchPathError{}},
+		{name: "escaped keys", doc: `{"/": 9, "~1": 10}`, patch: `[{"op": "test", "path": "/~01", "value": 10}]`, want: `{"/": 9, "~1": 10}`},
+		{name: "null value", doc: `{"foo": "bar"}`, patch: `[{"op": "add", "path": "/baz", "value": null}]`, want: `{"foo": "bar", "baz": null}`},
+		{name: "append", doc: `{"foo": ["bar"]}`, patch: `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`, want: `{"foo": ["bar", ["abc", "def"]]}`},
+
+		{name: "copy", doc: `{"a": {"b": [1]}}`, patch: `[{"op": "copy", "from": "/a", "path": "/c"}, {"op": "add", "path": "/c/b/-", "value": 2}]`, want: `{"a": {"b": [1]}, "c": {"b": [1, 2]}}`},
+		{name: "replace document", doc: `{"a": 1}`, patch: `[{"op": "replace", "path": "", "value": [1]}]`, want: `[1]`},
+		{name: "remove missing", doc: `{"a": 1}`, patch: `[{"op": "remove", "path": "/b"}]`, err: &PatchPathError{}},
+		{name: "replace missing", doc: `{"a": 1}`, patch: `[{"op": "replace", "path": "/b", "value": 2}]`, err: &PatchPathError{}},
+		{name: "index out of range", doc: `[1, 2]`, patch: `[{"op": "add", "path": "/3", "value": 3}]`, err: &PatchPathError{}},
+		{name: "leading zero", doc: `[1, 2]`, patch: `[{"op": "remove", "path": "/01"}]`, err: &PatchPathError{}},
+		{name: "signed index", doc: `[1, 2]`, patch: `[{"op": "test", "path": "/+0", "value": 1}]`, err: &PatchTestError{}},
+		{name: "copy from signed index", doc: `{"a": [1]}`, patch: `[{"op": "copy", "from": "/a/-0", "path": "/b"}]`, err: &PatchPathError{}},
+		{name: "atomic", doc: `{"a": 1}`, patch: `[{"op": "remove", "path": "/a"}, {"op": "test", "path": "/a", "value": 1}]`, err: &PatchTestError{}},
+		{name: "missing value", doc: `{}`, patch: `[{"op": "add", "path": "/a"}]`, err: &InvalidPatchError{}},
+		{name: "unknown op", doc: `{}`, patch: `[{"op": "merge", "path": "/a"}]`, err: &InvalidPatchError{}},
+		{name: "invalid path", doc: `{}`, patch: `[{"op": "remove", "path": "a"}]`, err: &InvalidPatchError{}},
+		{name: "move into itself", doc: `{"a": {}}`, patch: `[{"op": "move", "from": "/a", "path": "/a/b"}]`, err: &InvalidPatchError{}},
+		{name: "not an array", doc: `{}`, patch: `{"op": "remove", "path": "/a"}`, err: &InvalidPatchError{}},
+	}
+
+	for _, test := range tests {
+		patch, err := ParseJSONPatch([]byte(test.patch))
+		var out []byte
+		if err == nil {
+			out, err = patch.Apply([]byte(test.doc))
+		}
+
+		if test.err != nil {
+			if reflect.TypeOf(err) != reflect.TypeOf(test.err) {
+				t.Errorf("%s: expected %T, got %v\n", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v\n", test.name, err)
+			continue
+		}
+		if !sameJSON(t, out, []byte(test.want)) {
+			t.Errorf("%s: expected %s, got %s\n", test.name, test.want, out)
+		}
+	}
+}
+
+func TestJSONPatchErrorDetails(t *testing.T) {
+	patch := JSONPatch{
+		{Op: "test", Path: "/a", Value: json.RawMessage(`1`)},
+		{Op: "remove", Path: "/b/c"},
+	}
+	_, err := patch.Apply([]byte(`{"a": 1, "b": []}`))
+
+	var pathErr *PatchPathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("expected *PatchPathError, got %v", err)
+	}
+	if pathErr.Index != 1 || pathErr.Path != "/b/c" || pathErr.Op != "remove" || pathErr.StatusCode() != http.StatusConflict {
+		t.Errorf("unexpected error %+v\n", pathErr)
+	}
+
+	p := ProblemFromError(err, http.StatusInternalServerError)
+	if p.Status != http.StatusConflict || p.Extensions["path"] != "/b/c" || p.Extensions["operation"] != 1 {
+		t.Errorf("unexpected problem %+v\n", p)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// Examples of RFC 7396, appendix A.
+	tests := []struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		{doc: `{"a": "b"}`, patch: `{"a": "c"}`, want: `{"a": "c"}`},
+		{doc: `{"a": "b"}`, patch: `{"b": "c"}`, want: `{"a": "b", "b": "c"}`},
+		{doc: `{"a": "b"}`, patch: `{"a": null}`, want: `{}`},
+		{doc: `{"a": "b", "b": "c"}`, patch: `{"a": null}`, want: `{"b": "c"}`},
+		{doc: `{"a": ["b"]}`, patch: `{"a": "c"}`, want: `{"a": "c"}`},
+		{doc: `{"a": "c"}`, patch: `{"a": ["b"]}`, want: `{"a": ["b"]}`},
+		{doc: `{"a": {"b": "c"}}`, patch: `{"a": {"b": "d", "c": null}}`, want: `{"a": {"b": "d"}}`},
+		{doc: `{"a": [{"b": "c"}]}`, patch: `{"a": [1]}`, want: `{"a": [1]}`},
+		{doc: `["a", "b"]`, patch: `["c", "d"]`, want: `["c", "d"]`},
+		{doc: `{"a": "b"}`, patch: `["c"]`, want: `["c"]`},
+		{doc: `{"a": "foo"}`, patch: `null`, want: `null`},
+		{doc: `{"a": "foo"}`, patch: `"bar"`, want: `"bar"`},
+		{doc: `{"e": null}`, patch: `{"a": 1}`, want: `{"e": null, "a": 1}`},
+		{doc: `[1, 2]`, patch: `{"a": "b", "c": null}`, want: `{"a": "b"}`},
+		{doc: `{}`, patch: `{"a": {"bb": {"ccc": null}}}`, want: `{"a": {"bb": {}}}`},
+	}
+
+	for _, test := range tests {
+		out, err := MergePatch([]byte(test.doc), []byte(test.patch))
+		if err != nil {
+			t.Errorf("%s with %s: unexpected error: %v\n", test.doc, test.patch, err)
+			continue
+		}
+		if !sameJSON(t, out, []byte(test.want)) {
+			t.Errorf("%s with %s: expected %s, got %s\n", test.doc, test.patch, test.want, out)
+		}
+	}
+}
+
+type patchUser struct {
+	Name  string   `json:"name"`
+	Email string   `json:"email,omitempty"`
+	Tags  []string `json:"tags"`
+}
+
+func TestPatchJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		tool        Tools
+		want        patchUser
+		status      int
+	}{
+		{name: "json patch", contentType: JSONPatchContentType, body: `[{"op": "replace", "path": "/name", "value": "Bob"}, {"op": "add", "path": "/tags/-", "value": "admin"}]`, want: patchUser{Name: "Bob", Email: "alice@example.com", Tags: []string{"user", "admin"}}},
+		{name: "merge patch", contentType: MergePatchContentType + "; charset=utf-8", body: `{"email": null, "tags": []}`, want: patchUser{Name: "Alice", Tags: []string{}}},
+		{name: "failed test", contentType: JSONPatchContentType, body: `[{"op": "test", "path": "/name", "value": "Bob"}]`, status: http.StatusConflict},
+		{name: "wrong type", contentType: MergePatchContentType, body: `{"name": 1}`, status: http.StatusBadRequest},
+		{name: "unknown field", contentType: MergePatchContentType, body: `{"age": 1}`, status: http.StatusBadRequest},
+		{name: "allowed unknown field", contentType: MergePatchContentType, body: `{"age": 1}`, tool: Tools{AllowUnknownFields: true}, want: patchUser{Name: "Alice", Email: "alice@example.com", Tags: []string{"user"}}},
+		{name: "too large", contentType: JSONPatchContentType, body: `[{"op": "remove", "path": "/email"}]`, tool: Tools{MaxJSONSize: 10}, status: http.StatusRequestEntityTooLarge},
+		{name: "plain json", contentType: "application/json", body: `{}`, status: http.StatusUnsupportedMediaType},
+		{name: "invalid patch", contentType: JSONPatchContentType, body: `[{"path": "/name"}]`, status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		user := patchUser{Name: "Alice", Email: "alice@example.com", Tags: []string{"user"}}
+		original := user
+
+		req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		rr := httptest.NewRecorder()
+		err := test.tool.PatchJSON(rr, req, &user)
+
+		if test.status != 0 {
+			var statusErr StatusCoder
+			if !errors.As(err, &statusErr) || statusErr.StatusCode() != test.status {
+				t.Errorf("%s: expected error with status %d, got %v\n", test.name, test.status, err)
+			}
+			if user.Name != original.Name || user.Email != original.Email || len(user.Tags) != len(original.Tags) {
+				t.Errorf("%s: value was modified: %+v\n", test.name, user)
+			}
+			if test.status == http.StatusUnsupportedMediaType && rr.Header().Get("Accept-Patch") == "" {
+				t.Errorf("%s: expected Accept-Patch header\n", test.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v\n", test.name, err)
+			continue
+		}
+		got, _ := json.Marshal(user)
+		want, _ := json.Marshal(test.want)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: expected %s, got %s\n", test.name, want, got)
+		}
+	}
+}
+
+func TestApplyPatchValidates(t *testing.T) {
+	schemas := NewSchemaRegistry()
+	schemas.Register(patchUser{}, MustCompileSchema(`{"properties": {"name": {"minLength": 1}}}`))
+	tool := Tools{Schemas: schemas}
+
+	user := patchUser{Name: "Alice"}
+	err := tool.ApplyMergePatch([]byte(`{"name": ""}`), &user)
+	var schemaErrs SchemaErrors
+	if !errors.As(err, &schemaErrs) || user.Name != "Alice" {
+		t.Errorf("expected SchemaErrors, got %v and %+v\n", err, user)
+	}
+
+	if err := tool.ApplyMergePatch([]byte(`{}`), user); err == nil {
+		t.Error("expected an error for a non-pointer target")
+	}
+}
+
+type patchAccount struct {
+	Name     string `json:"name"`
+	Email    string `json:"email,omitempty"`
+	Password string `json:"-"`
+	Profile  struct {
+		Bio    string `json:"bio,omitempty"`
+		secret string
+	} `json:"profile"`
+	id int
+}
+
+func TestApplyPatchKeepsHiddenFields(t *testing.T) {
+	var tool Tools
+	newAccount := func() *patchAccount {
+		a := &patchAccount{Name: "a", Email: "a@example.com", Password: "hash", id: 42}
+		a.Profile.Bio = "bio"
+		a.Profile.secret = "s"
+		return a
+	}
+
+	tests := []struct {
+		name  string
+		apply func(a *patchAccount) error
+	}{
+		{name: "json patch", apply: func(a *patchAccount) error {
+			return tool.ApplyJSONPatch(JSONPatch{
+				{Op: "replace", Path: "/name", Value: json.RawMessage(`"c"`)},
+				{Op: "remove", Path: "/email"},
+				{Op: "remove", Path: "/profile/bio"},
+			}, a)
+		}},
+		{name: "merge patch", apply: func(a *patchAccount) error {
+			return tool.ApplyMergePatch([]byte(`{"name": "c", "email": null, "profile": {"bio": null}}`), a)
+		}},
+	}
+
+	for _, test := range tests {
+		a := newAccount()
+		if err := test.apply(a); err != nil {
+			t.Errorf("%s: unexpected error: %v\n", test.name, err)
+			continue
+		}
+		if a.Name != "c" || a.Email != "" || a.Profile.Bio != "" {
+			t.Errorf("%s: patch not applied: %+v\n", test.name, a)
+		}
+		if a.Password != "hash" || a.id != 42 || a.Profile.secret != "s" {
+			t.Errorf("%s: hidden fields were lost: %+v\n", test.name, a)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ProblemContentType is the media type of problem details documents.
+const ProblemContentType = "application/problem+json"
+
+// Problem is a problem details document as defined by RFC 9457. It implements error, so handlers can
+// return it (see ProblemHandler) and ErrorJSON writes it as it is. Extensions are additional members,
+// which are written next to the standard members.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+// NewProblem creates a Problem with the given status and detail. Title is the status text and Type is
+// "about:blank", as RFC 9457 suggests for problems that need no further semantics than the status.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Error implements the error interface.
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	if p.Title == "" {
+		return p.Detail
+	}
+	return p.Title + ": " + p.Detail
+}
+
+// StatusCode returns the status of the problem or http.StatusInternalServerError if none is set.
+func (p *Problem) StatusCode() int {
+	if p.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return p.Status
+}
+
+// With sets the extension member key to value and returns p.
+func (p *Problem) With(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]interface{})
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// MarshalJSON writes the standard members and the extensions into one JSON object. Extensions can't
+// overwrite standard members.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+
+	members["type"] = p.Type
+	if p.Type == "" {
+		members["type"] = "about:blank"
+	}
+	members["status"] = p.StatusCode()
+	members["title"] = p.Title
+	if p.Title == "" {
+		members["title"] = http.StatusText(p.StatusCode())
+	}
+	delete(members, "detail")
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	delete(members, "instance")
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+
+	return json.Marshal(members)
+}
+
+// UnmarshalJSON reads a problem details document. Unknown members are stored in Extensions.
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	*p = Problem{}
+	fields := map[string]interface{}{
+		"type":     &p.Type,
+		"title":    &p.Title,
+		"status":   &p.Status,
+		"detail":   &p.Detail,
+		"instance": &p.Instance,
+	}
+	for k, raw := range members {
+		if field, ok := fields[k]; ok {
+			// RFC 9457 demands to ignore members of the wrong type.
+			_ = json.Unmarshal(raw, field)
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		p.With(k, value)
+	}
+	return nil
+}
+
+// ProblemFromError converts err into a Problem. A *Problem in err's chain is returned as it is. Errors of
+// ReadJSON and Validate get their status and the details as extension members, e.g. "field" for a
+// *JSONTypeError or "errors" for ValidationErrors and SchemaErrors. Other errors get the status of a
+// StatusCoder in their chain or status, with the error message as detail. Server errors (5xx) only get
+// the status text as detail, so that internal messages like file paths don't reach the client.
+func ProblemFromError(err error, status int) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem
+	}
+
+	var statusErr StatusCoder
+	if errors.As(err, &statusErr) {
+		status = statusErr.StatusCode()
+	}
+	return problemFromError(err, status)
+}
+
+// problemFromError converts err, which is not a *Problem, into a Problem with the given status.
+func problemFromError(err error, status int) *Problem {
+	if status >= 500 {
+		return NewProblem(status, http.StatusText(status))
+	}
+	p := NewProblem(status, err.Error())
+
+	var (
+		validationErrs ValidationErrors
+		schemaErrs     SchemaErrors
+		syntaxErr      *JSONSyntaxError
+		typeErr        *JSONTypeError
+		unknownErr     *JSONUnknownFieldError
+		tooLargeErr    *JSONTooLargeError
+		tooManyErr     *JSONTooManyElementsError
+		patchPathErr   *PatchPathError
+		patchTestErr   *PatchTestError
+	)
+	switch {
+	case errors.As(err, &validationErrs):
+		p.Detail = "The request contains invalid fields."
+		p.With("errors", validationErrs)
+	case errors.As(err, &schemaErrs):
+		p.Detail = "The request doesn't match its schema."
+		p.With("errors", schemaErrs)
+	case errors.As(err, &syntaxErr):
+		p.With("line", syntaxErr.Line).With("column", syntaxErr.Column)
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			p.With("field", typeErr.Field)
+		}
+		p.With("expected", typeErr.Expected)
+	case errors.As(err, &unknownErr):
+		p.With("field", unknownErr.Field)
+	case errors.As(err, &tooLargeErr):
+		p.With("limit", tooLargeErr.Limit)
+	case errors.As(err, &tooManyErr):
+		p.With("limit", tooManyErr.Limit)
+	case errors.As(err, &patchPathErr):
+		p.With("operation", patchPathErr.Index).With("path", patchPathErr.Path)
+	case errors.As(err, &patchTestErr):
+		p.With("operation", patchTestErr.Index).With("path", patchTestErr.Path)
+	}
+
+	return p
+}
+
+// WriteProblem writes p as application/problem+json with its status, encoded according to JSON.
+func (t *Tools) WriteProblem(w http.ResponseWriter, p *Problem, headers ...http.Header) error {
+	return t.writeProblem(w, nil, p, headers...)
+}
+
+// writeProblem writes p for a response to r, which may be nil.
+func (t *Tools) writeProblem(w http.ResponseWriter, r *http.Request, p *Problem, headers ...http.Header) error {
+	body, err := t.encodeJSON(r, p)
+	if err != nil {
+		return err
+	}
+	defer body.release()
+
+	if len(headers) > 0 {
+		for k, v := range headers[0] {
+			w.Header()[k] = v
+		}
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	return t.writeBody(w, r, p.StatusCode(), body.Bytes())
+}
+
+// ProblemHandler adapts a handler that returns an error to http.Handler. A returned error is written as
+// problem details document (see ProblemFromError), with the request path as instance unless the
+// problem has one already. Errors without a status result in 500 Internal Server Error. If the handler
+// has started the response already, e.g. by streaming, the error can't be written and is dropped.
+func (t *Tools) ProblemHandler(fn func(w http.ResponseWriter, r *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tw := &trackingResponseWriter{ResponseWriter: w}
+		err := fn(tw, r)
+		if err == nil || tw.started {
+			return
+		}
+
+		p := ProblemFromError(err, http.StatusInternalServerError)
+		if p.Instance == "" {
+			// Don't modify a *Problem owned by the handler.
+			copied := *p
+			copied.Instance = r.URL.Path
+			p = &copied
+		}
+		_ = t.writeProblem(w, r, p)
+	})
+}
+
+// trackingResponseWriter records whether the response has been started.
+type trackingResponseWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+// WriteHeader implements http.ResponseWriter. Informational headers don't start the response.
+func (tw *trackingResponseWriter) WriteHeader(status int) {
+	if status >= 200 {
+		tw.started = true
+	}
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (tw *trackingResponseWriter) Write(p []byte) (int, error) {
+	tw.started = true
+	return tw.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher if the underlying writer does.
+func (tw *trackingResponseWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		tw.started = true
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (tw *trackingResponseWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
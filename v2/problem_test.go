@@ -0,0 +1,249 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProblemMarshalJSON(t *testing.T) {
+	p := NewProblem(http.StatusForbidden, "Your balance is 30, but that costs 50.")
+	p.Type = "https://example.com/probs/out-of-credit"
+	p.Instance = "/account/12345/msgs/abc"
+	p.With("balance", 30).With("status", 200)
+
+	out, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var members map[string]interface{}
+	if err := json.Unmarshal(out, &members); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"type":     "https://example.com/probs/out-of-credit",
+		"title":    "Forbidden",
+		"status":   float64(403),
+		"detail":   "Your balance is 30, but that costs 50.",
+		"instance": "/account/12345/msgs/abc",
+		"balance":  float64(30),
+	}
+	if len(members) != len(want) {
+		t.Errorf("expected %d members, got %s\n", len(want), out)
+	}
+	for k, v := range want {
+		if members[k] != v {
+			t.Errorf("%s: expected %v, got %v\n", k, v, members[k])
+		}
+	}
+
+	var back Problem
+	if err := json.Unmarshal(out, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Status != 403 || back.Type != p.Type || back.Extensions["balance"] != float64(30) {
+		t.Errorf("unexpected round trip result %+v\n", back)
+	}
+}
+
+func TestProblemFromError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		status    int
+		extension string
+	}{
+		{name: "plain", err: errors.New("boom"), status: http.StatusBadRequest},
+		{name: "validation", err: ValidationErrors{{Field: "name", Rule: "required", Message: "is required"}}, status: http.StatusUnprocessableEntity, extension: "errors"},
+		{name: "syntax", err: &JSONSyntaxError{Line: 2, Column: 5}, status: http.StatusBadRequest, extension: "line"},
+		{name: "type", err: &JSONTypeError{Field: "age", Expected: "number", Value: "string"}, status: http.StatusBadRequest, extension: "field"},
+		{name: "unknown field", err: &JSONUnknownFieldError{Field: "extra"}, status: http.StatusBadRequest, extension: "field"},
+		{name: "too large", err: fmt.Errorf("reading: %w", &JSONTooLargeError{Limit: 10}), status: http.StatusRequestEntityTooLarge, extension: "limit"},
+		{name: "problem", err: NewProblem(http.StatusConflict, "exists"), status: http.StatusConflict},
+	}
+
+	for _, test := range tests {
+		p := ProblemFromError(test.err, http.StatusBadRequest)
+		if p.Status < 500 && p.Detail == "" {
+			t.Errorf("%s: expected a detail\n", test.name)
+		}
+		if p.Status != test.status {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, test.status, p.Status)
+		}
+		if p.Title != http.StatusText(test.status) {
+			t.Errorf("%s: unexpected title %q\n", test.name, p.Title)
+		}
+		if test.extension != "" {
+			if _, ok := p.Extensions[test.extension]; !ok {
+				t.Errorf("%s: expected extension %q, got %v\n", test.name, test.extension, p.Extensions)
+			}
+		}
+	}
+}
+
+func TestProblemFromServerError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		detail string
+	}{
+		{name: "plain", err: errors.New("open /srv/data/users.db: permission denied"), status: http.StatusInternalServerError, detail: "Internal Server Error"},
+		{name: "status coder", err: &DownloadError{Name: "/srv/files/a.txt", Status: http.StatusInternalServerError, Err: errors.New("no download root configured")}, status: http.StatusInternalServerError, detail: "Internal Server Error"},
+		{name: "unavailable", err: fmt.Errorf("query: %w", NewProblem(http.StatusServiceUnavailable, "maintenance until 10:00")), status: http.StatusServiceUnavailable, detail: "maintenance until 10:00"},
+	}
+
+	for _, test := range tests {
+		p := ProblemFromError(test.err, http.StatusInternalServerError)
+		if p.Status != test.status || p.Detail != test.detail || len(p.Extensions) != 0 {
+			t.Errorf("%s: expected status %d and detail %q, got %+v\n", test.name, test.status, test.detail, p)
+		}
+	}
+}
+
+func TestErrorJSONProblemDetails(t *testing.T) {
+	tool := Tools{ProblemDetails: true}
+
+	rr := httptest.NewRecorder()
+	err := tool.ErrorJSON(rr, ValidationErrors{{Field: "email", Rule: "email", Message: "must be a valid email address"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422, got %d\n", rr.Code)
+	}
+	if got := rr.Header().Get("Content-Type"); got != ProblemContentType {
+		t.Errorf("expected content type %s, got %s\n", ProblemContentType, got)
+	}
+
+	var payload struct {
+		Status int          `json:"status"`
+		Errors []FieldError `json:"errors"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Status != 422 || len(payload.Errors) != 1 || payload.Errors[0].Field != "email" {
+		t.Errorf("unexpected payload %+v\n", payload)
+	}
+
+	rr = httptest.NewRecorder()
+	_ = tool.ErrorJSON(rr, &JSONTooLargeError{Limit: 10}, http.StatusTeapot)
+	var p Problem
+	if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusTeapot || p.Status != http.StatusTeapot || p.Title != http.StatusText(http.StatusTeapot) {
+		t.Errorf("expected explicit status to win, got %d and %+v\n", rr.Code, p)
+	}
+
+	custom := &Problem{Type: "https://example.com/out-of-stock", Title: "Out of stock", Status: http.StatusConflict, Detail: "Only 2 left."}
+	for _, status := range [][]int{nil, {http.StatusBadRequest}} {
+		rr = httptest.NewRecorder()
+		_ = tool.ErrorJSON(rr, fmt.Errorf("ordering: %w", custom), status...)
+		p = Problem{}
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if rr.Code != http.StatusConflict || p.Title != custom.Title || p.Status != custom.Status || p.Type != custom.Type {
+			t.Errorf("status %v: expected the problem unchanged, got %d and %+v\n", status, rr.Code, p)
+		}
+	}
+	if custom.Status != http.StatusConflict || custom.Title != "Out of stock" {
+		t.Errorf("expected the caller's problem not to be modified, got %+v\n", custom)
+	}
+}
+
+func TestProblemHandler(t *testing.T) {
+	tool := Tools{}
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{name: "no error", status: http.StatusOK},
+		{name: "problem", err: NewProblem(http.StatusNotFound, "no such widget"), status: http.StatusNotFound},
+		{name: "plain", err: errors.New("database down"), status: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		h := tool.ProblemHandler(func(w http.ResponseWriter, r *http.Request) error {
+			return test.err
+		})
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/widgets/1", nil))
+		if rr.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, test.status, rr.Code)
+		}
+		if test.err == nil {
+			continue
+		}
+
+		var p Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Instance != "/widgets/1" {
+			t.Errorf("%s: expected instance /widgets/1, got %q\n", test.name, p.Instance)
+		}
+	}
+}
+
+func TestProblemHandlerStartedResponse(t *testing.T) {
+	tool := Tools{}
+
+	tests := []struct {
+		name   string
+		fn     func(w http.ResponseWriter, r *http.Request) error
+		status int
+		body   string
+	}{
+		{
+			name: "error written by the handler",
+			fn: func(w http.ResponseWriter, r *http.Request) error {
+				http.Error(w, "Not Found", http.StatusNotFound)
+				return &DownloadError{Name: "a.txt", Status: http.StatusNotFound, Err: fs.ErrNotExist}
+			},
+			status: http.StatusNotFound,
+			body:   "Not Found\n",
+		},
+		{
+			name: "streamed",
+			fn: func(w http.ResponseWriter, r *http.Request) error {
+				_, _ = io.WriteString(w, "partial")
+				w.(http.Flusher).Flush()
+				return errors.New("connection lost")
+			},
+			status: http.StatusOK,
+			body:   "partial",
+		},
+		{
+			name: "headers only",
+			fn: func(w http.ResponseWriter, r *http.Request) error {
+				w.Header().Set("X-Request-Id", "1")
+				return NewProblem(http.StatusConflict, "exists")
+			},
+			status: http.StatusConflict,
+		},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		tool.ProblemHandler(test.fn).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/files/a.txt", nil))
+		if rr.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, test.status, rr.Code)
+		}
+		if test.body != "" && rr.Body.String() != test.body {
+			t.Errorf("%s: expected a single body %q, got %q\n", test.name, test.body, rr.Body.String())
+		}
+	}
+}
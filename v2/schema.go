@@ -0,0 +1,768 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Schema is a compiled JSON Schema. It supports this subset of draft 2020-12: boolean schemas, type, enum,
+// const, properties, required, additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, allOf, anyOf, oneOf, not and $ref to
+// locations within the same document, e.g. "#/$defs/address". Other keywords are ignored.
+type Schema struct {
+	root *schemaNode
+}
+
+// schemaNode is a compiled (sub)schema.
+type schemaNode struct {
+	always *bool
+
+	types      []string
+	enum       []interface{}
+	hasConst   bool
+	constValue interface{}
+
+	properties map[string]*schemaNode
+	required   []string
+	additional *schemaNode
+	items      *schemaNode
+	minItems   *int
+	maxItems   *int
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+
+	allOf []*schemaNode
+	anyOf []*schemaNode
+	oneOf []*schemaNode
+	not   *schemaNode
+	ref   *schemaNode
+}
+
+// CompileSchema compiles the JSON Schema document data.
+func CompileSchema(data []byte) (*Schema, error) {
+	doc, err := decodeGenericJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+
+	c := &schemaCompiler{doc: doc, nodes: make(map[string]*schemaNode)}
+	root, err := c.compile(doc, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkCycles(); err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// MustCompileSchema is like CompileSchema but panics if the schema can't be compiled. It simplifies the
+// initialization of global variables holding schemas.
+func MustCompileSchema(data string) *Schema {
+	s, err := CompileSchema([]byte(data))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// decodeGenericJSON decodes a single JSON value into maps, slices, strings, json.Numbers, bools and nils.
+func decodeGenericJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return nil, &JSONMultipleValuesError{}
+	}
+	return v, nil
+}
+
+// schemaCompiler compiles the subschemas of a document. Subschemas are cached by their location, so
+// that recursive references terminate.
+type schemaCompiler struct {
+	doc   interface{}
+	nodes map[string]*schemaNode
+}
+
+// schemaTypes are the values of the type keyword.
+var schemaTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true, "number": true, "integer": true, "string": true,
+}
+
+func (c *schemaCompiler) compile(v interface{}, location string) (*schemaNode, error) {
+	if node, ok := c.nodes[location]; ok {
+		return node, nil
+	}
+	node := &schemaNode{}
+	c.nodes[location] = node
+
+	if b, ok := v.(bool); ok {
+		node.always = &b
+		return node, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, schemaCompileError(location, "a schema must be an object or a boolean")
+	}
+
+	var err error
+	for keyword, value := range m {
+		at := location + "/" + escapeJSONPointer(keyword)
+		switch keyword {
+		case "type":
+			err = c.compileTypes(node, value, at)
+		case "enum":
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, schemaCompileError(at, "must be an array")
+			}
+			node.enum = list
+		case "const":
+			node.hasConst, node.constValue = true, value
+		case "properties":
+			props, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, schemaCompileError(at, "must be an object")
+			}
+			node.properties = make(map[string]*schemaNode, len(props))
+			for name, prop := range props {
+				if node.properties[name], err = c.compile(prop, at+"/"+escapeJSONPointer(name)); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, schemaCompileError(at, "must be an array of strings")
+			}
+			for _, name := range list {
+				s, ok := name.(string)
+				if !ok {
+					return nil, schemaCompileError(at, "must be an array of strings")
+				}
+				node.required = append(node.required, s)
+			}
+		case "additionalProperties":
+			node.additional, err = c.compile(value, at)
+		case "items":
+			node.items, err = c.compile(value, at)
+		case "minItems":
+			node.minItems, err = schemaCount(value, at)
+		case "maxItems":
+			node.maxItems, err = schemaCount(value, at)
+		case "minLength":
+			node.minLength, err = schemaCount(value, at)
+		case "maxLength":
+			node.maxLength, err = schemaCount(value, at)
+		case "pattern":
+			s, ok := value.(string)
+			if !ok {
+				return nil, schemaCompileError(at, "must be a string")
+			}
+			if node.pattern, err = regexp.Compile(s); err != nil {
+				return nil, schemaCompileError(at, "invalid pattern: %v", err)
+			}
+		case "minimum":
+			node.minimum, err = schemaNumber(value, at)
+		case "maximum":
+			node.maximum, err = schemaNumber(value, at)
+		case "exclusiveMinimum":
+			node.exclusiveMinimum, err = schemaNumber(value, at)
+		case "exclusiveMaximum":
+			node.exclusiveMaximum, err = schemaNumber(value, at)
+		case "allOf":
+			node.allOf, err = c.compileList(value, at)
+		case "anyOf":
+			node.anyOf, err = c.compileList(value, at)
+		case "oneOf":
+			node.oneOf, err = c.compileList(value, at)
+		case "not":
+			node.not, err = c.compile(value, at)
+		case "$ref":
+			node.ref, err = c.compileRef(value, at)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (c *schemaCompiler) compileTypes(node *schemaNode, value interface{}, at string) error {
+	var names []interface{}
+	switch v := value.(type) {
+	case string:
+		names = []interface{}{v}
+	case []interface{}:
+		names = v
+	default:
+		return schemaCompileError(at, "must be a string or an array of strings")
+	}
+	for _, name := range names {
+		s, ok := name.(string)
+		if !ok || !schemaTypes[s] {
+			return schemaCompileError(at, "invalid type %v", name)
+		}
+		node.types = append(node.types, s)
+	}
+	return nil
+}
+
+func (c *schemaCompiler) compileList(value interface{}, at string) ([]*schemaNode, error) {
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, schemaCompileError(at, "must be a non-empty array of schemas")
+	}
+	nodes := make([]*schemaNode, len(list))
+	for i, v := range list {
+		var err error
+		if nodes[i], err = c.compile(v, at+"/"+strconv.Itoa(i)); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// compileRef resolves a reference to a location within the document.
+func (c *schemaCompiler) compileRef(value interface{}, at string) (*schemaNode, error) {
+	ref, ok := value.(string)
+	if !ok || !strings.HasPrefix(ref, "#") {
+		return nil, schemaCompileError(at, "only references within the document are supported")
+	}
+	pointer, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, schemaCompileError(at, "invalid reference %q", ref)
+	}
+
+	target, ok := resolveJSONPointer(c.doc, pointer)
+	if !ok {
+		return nil, schemaCompileError(at, "reference %q not found", ref)
+	}
+	return c.compile(target, pointer)
+}
+
+// checkCycles rejects references that lead back to the same subschema without descending into the
+// document, e.g. {"$ref": "#"}, as validating them would never end.
+func (c *schemaCompiler) checkCycles() error {
+	locations := make(map[*schemaNode]string, len(c.nodes))
+	for location, node := range c.nodes {
+		locations[node] = location
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[*schemaNode]int, len(c.nodes))
+	var visit func(n *schemaNode) error
+	visit = func(n *schemaNode) error {
+		switch state[n] {
+		case visiting:
+			return schemaCompileError(locations[n], "reference cycle")
+		case done:
+			return nil
+		}
+		state[n] = visiting
+		// These keywords apply to the same value as n.
+		next := append(append(append([]*schemaNode{n.ref, n.not}, n.allOf...), n.anyOf...), n.oneOf...)
+		for _, m := range next {
+			if m == nil {
+				continue
+			}
+			if err := visit(m); err != nil {
+				return err
+			}
+		}
+		state[n] = done
+		return nil
+	}
+
+	keys := make([]string, 0, len(c.nodes))
+	for location := range c.nodes {
+		keys = append(keys, location)
+	}
+	sort.Strings(keys)
+	for _, location := range keys {
+		if err := visit(c.nodes[location]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaCount parses a non-negative integer keyword.
+func schemaCount(value interface{}, at string) (*int, error) {
+	n, ok := value.(json.Number)
+	if ok {
+		if i, err := n.Int64(); err == nil && i >= 0 && i <= math.MaxInt32 {
+			count := int(i)
+			return &count, nil
+		}
+	}
+	return nil, schemaCompileError(at, "must be a non-negative integer")
+}
+
+// schemaNumber parses a numeric keyword.
+func schemaNumber(value interface{}, at string) (*float64, error) {
+	n, ok := value.(json.Number)
+	if ok {
+		if f, err := n.Float64(); err == nil {
+			return &f, nil
+		}
+	}
+	return nil, schemaCompileError(at, "must be a number")
+}
+
+func schemaCompileError(location, format string, args ...interface{}) error {
+	if location == "" {
+		location = "/"
+	}
+	return fmt.Errorf("schema: %s: %s", location, fmt.Sprintf(format, args...))
+}
+
+// escapeJSONPointer escapes a reference token of a JSON Pointer (RFC 6901).
+func escapeJSONPointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// unescapeJSONPointer reverses escapeJSONPointer.
+func unescapeJSONPointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
+
+// resolveJSONPointer returns the value pointer refers to within doc.
+func resolveJSONPointer(doc interface{}, pointer string) (interface{}, bool) {
+	if pointer == "" {
+		return doc, true
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, false
+	}
+
+	v := doc
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = unescapeJSONPointer(token)
+		switch container := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = container[token]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, ok := arrayIndex(token, len(container))
+			if !ok {
+				return nil, false
+			}
+			v = container[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// SchemaError is a violation of a schema. Pointer is the JSON Pointer (RFC 6901) to the offending value
+// of the document, e.g. "/items/0/name"; it is empty for the document itself.
+type SchemaError struct {
+	Pointer string `json:"pointer"`
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface.
+func (e SchemaError) Error() string {
+	pointer := e.Pointer
+	if pointer == "" {
+		pointer = "(root)"
+	}
+	return pointer + " " + e.Message
+}
+
+// SchemaErrors are all violations of a schema by a document.
+type SchemaErrors []SchemaError
+
+// Error implements the error interface.
+func (s SchemaErrors) Error() string {
+	messages := make([]string, len(s))
+	for i, e := range s {
+		messages[i] = e.Error()
+	}
+	return "schema validation failed: " + strings.Join(messages, "; ")
+}
+
+// StatusCode returns http.StatusUnprocessableEntity.
+func (s SchemaErrors) StatusCode() int { return http.StatusUnprocessableEntity }
+
+// ValidateJSON validates the JSON document data. Violations are returned as SchemaErrors, other
+// errors if data is not valid JSON.
+func (s *Schema) ValidateJSON(data []byte) error {
+	doc, err := decodeGenericJSON(data)
+	if err != nil {
+		return err
+	}
+	return s.Validate(doc)
+}
+
+// Validate validates a document decoded with json.Decoder.UseNumber into interface{}. Violations are
+// returned as SchemaErrors.
+func (s *Schema) Validate(doc interface{}) error {
+	var errs SchemaErrors
+	s.root.validate(doc, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// matches reports whether v is valid without collecting the violations.
+func (n *schemaNode) matches(v interface{}, pointer string) bool {
+	var errs SchemaErrors
+	n.validate(v, pointer, &errs)
+	return len(errs) == 0
+}
+
+func (n *schemaNode) validate(v interface{}, pointer string, errs *SchemaErrors) {
+	fail := func(keyword, format string, args ...interface{}) {
+		*errs = append(*errs, SchemaError{Pointer: pointer, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if n.always != nil {
+		if !*n.always {
+			fail("false", "is not allowed")
+		}
+		return
+	}
+	if n.ref != nil {
+		n.ref.validate(v, pointer, errs)
+	}
+
+	if len(n.types) > 0 && !schemaTypeMatches(n.types, v) {
+		fail("type", "must be of type %s", strings.Join(n.types, " or "))
+		// The other keywords would only repeat the error.
+		return
+	}
+	if n.enum != nil {
+		found := false
+		for _, e := range n.enum {
+			if jsonEqual(v, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("enum", "must be one of %s", formatJSONValues(n.enum))
+		}
+	}
+	if n.hasConst && !jsonEqual(v, n.constValue) {
+		fail("const", "must be %s", formatJSONValues([]interface{}{n.constValue}))
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		n.validateObject(v, pointer, errs, fail)
+	case []interface{}:
+		if n.minItems != nil && len(v) < *n.minItems {
+			fail("minItems", "must have at least %d items", *n.minItems)
+		}
+		if n.maxItems != nil && len(v) > *n.maxItems {
+			fail("maxItems", "must have at most %d items", *n.maxItems)
+		}
+		if n.items != nil {
+			for i, item := range v {
+				n.items.validate(item, pointer+"/"+strconv.Itoa(i), errs)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if n.minLength != nil && length < *n.minLength {
+			fail("minLength", "must be at least %d characters long", *n.minLength)
+		}
+		if n.maxLength != nil && length > *n.maxLength {
+			fail("maxLength", "must be at most %d characters long", *n.maxLength)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(v) {
+			fail("pattern", "must match the pattern %s", n.pattern)
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if n.minimum != nil && f < *n.minimum {
+			fail("minimum", "must be at least %v", *n.minimum)
+		}
+		if n.maximum != nil && f > *n.maximum {
+			fail("maximum", "must be at most %v", *n.maximum)
+		}
+		if n.exclusiveMinimum != nil && f <= *n.exclusiveMinimum {
+			fail("exclusiveMinimum", "must be greater than %v", *n.exclusiveMinimum)
+		}
+		if n.exclusiveMaximum != nil && f >= *n.exclusiveMaximum {
+			fail("exclusiveMaximum", "must be less than %v", *n.exclusiveMaximum)
+		}
+	}
+
+	for _, sub := range n.allOf {
+		sub.validate(v, pointer, errs)
+	}
+	if n.anyOf != nil {
+		matched := false
+		for _, sub := range n.anyOf {
+			if sub.matches(v, pointer) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("anyOf", "must match at least one of the schemas")
+		}
+	}
+	if n.oneOf != nil {
+		count := 0
+		for _, sub := range n.oneOf {
+			if sub.matches(v, pointer) {
+				count++
+			}
+		}
+		if count != 1 {
+			fail("oneOf", "must match exactly one of the schemas, matches %d", count)
+		}
+	}
+	if n.not != nil && n.not.matches(v, pointer) {
+		fail("not", "must not match the schema")
+	}
+}
+
+func (n *schemaNode) validateObject(v map[string]interface{}, pointer string, errs *SchemaErrors, fail func(keyword, format string, args ...interface{})) {
+	for _, name := range n.required {
+		if _, ok := v[name]; !ok {
+			*errs = append(*errs, SchemaError{Pointer: pointer + "/" + escapeJSONPointer(name), Keyword: "required", Message: "is required"})
+		}
+	}
+
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	// Report violations in a stable order.
+	sort.Strings(names)
+
+	for _, name := range names {
+		at := pointer + "/" + escapeJSONPointer(name)
+		if prop, ok := n.properties[name]; ok {
+			prop.validate(v[name], at, errs)
+		} else if n.additional != nil {
+			if n.additional.always != nil && !*n.additional.always {
+				*errs = append(*errs, SchemaError{Pointer: at, Keyword: "additionalProperties", Message: "is not allowed"})
+				continue
+			}
+			n.additional.validate(v[name], at, errs)
+		}
+	}
+}
+
+// schemaTypeMatches reports whether v is of one of the types.
+func schemaTypeMatches(types []string, v interface{}) bool {
+	for _, t := range types {
+		switch v := v.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case json.Number:
+			if t == "number" {
+				return true
+			}
+			if t == "integer" {
+				f, err := v.Float64()
+				if err == nil && f == math.Trunc(f) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// jsonEqual compares two generic JSON values. Numbers are equal if their values are.
+func jsonEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fa, errA := a.Float64()
+		fb, errB := b.Float64()
+		return errA == nil && errB == nil && fa == fb
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if w, ok := b[k]; !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// formatJSONValues formats values for error messages.
+func formatJSONValues(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		out, _ := json.Marshal(v)
+		parts[i] = string(out)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SchemaRegistry associates schemas with Go types. Assign it to Tools.Schemas to validate the bodies
+// ReadJSON and ReadJSONArray decode into these types. A SchemaRegistry is safe for concurrent use.
+type SchemaRegistry struct {
+	mu      sync.RWMutex
+	schemas map[reflect.Type]*Schema
+}
+
+// NewSchemaRegistry creates an empty registry.
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{schemas: make(map[reflect.Type]*Schema)}
+}
+
+// Register associates schema with the type of v, e.g. Order{}. Pointers to the type are associated,
+// too.
+func (r *SchemaRegistry) Register(v interface{}, schema *Schema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schemas[schemaType(v)] = schema
+}
+
+// Lookup returns the schema registered for the type of v.
+func (r *SchemaRegistry) Lookup(v interface{}) (*Schema, bool) {
+	if r == nil || v == nil {
+		return nil, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	schema, ok := r.schemas[schemaType(v)]
+	return schema, ok
+}
+
+// schemaType returns the type of v without pointers.
+func schemaType(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// ResponseSchemaError is returned by WriteJSON if ValidateResponses is set and the data violates its
+// schema.
+type ResponseSchemaError struct {
+	Errs SchemaErrors
+}
+
+// Error implements the error interface.
+func (e *ResponseSchemaError) Error() string {
+	return "response " + e.Errs.Error()
+}
+
+// Unwrap returns the violations.
+func (e *ResponseSchemaError) Unwrap() error { return e.Errs }
+
+// StatusCode returns http.StatusInternalServerError, as the server is at fault.
+func (e *ResponseSchemaError) StatusCode() int { return http.StatusInternalServerError }
+
+// validateSchema reads the document from dec, validates it against schema and returns a decoder and
+// input for the same document.
+func (t *Tools) validateSchema(dec *json.Decoder, input *jsonInput, schema *Schema) (*json.Decoder, *jsonInput, error) {
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, nil, input.error(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return nil, nil, &JSONMultipleValuesError{}
+	}
+	if err := schema.Validate(doc); err != nil {
+		return nil, nil, err
+	}
+
+	replay := &jsonInput{r: bytes.NewReader(input.read.Bytes()), maxBytes: input.maxBytes}
+	replayDec := json.NewDecoder(replay)
+	if !t.AllowUnknownFields {
+		replayDec.DisallowUnknownFields()
+	}
+	return replayDec, replay, nil
+}
+
+// validateResponse validates the JSON out written for data, if ValidateResponses is set.
+func (t *Tools) validateResponse(data interface{}, out []byte) error {
+	if !t.ValidateResponses {
+		return nil
+	}
+	schema, ok := t.Schemas.Lookup(data)
+	if !ok {
+		return nil
+	}
+
+	err := schema.ValidateJSON(out)
+	var errs SchemaErrors
+	if errors.As(err, &errs) {
+		return &ResponseSchemaError{Errs: errs}
+	}
+	return err
+}
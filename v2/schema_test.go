@@ -0,0 +1,260 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var orderSchema = MustCompileSchema(`{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["id", "items"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"status": {"enum": ["open", "paid"]},
+		"email": {"type": "string", "pattern": "^[^@]+@[^@]+$"},
+		"items": {"type": "array", "minItems": 1, "items": {"$ref": "#/$defs/item"}},
+		"parent": {"anyOf": [{"type": "null"}, {"$ref": "#"}]}
+	},
+	"$defs": {
+		"item": {
+			"type": "object",
+			"required": ["name"],
+			"properties": {
+				"name": {"type": "string", "minLength": 1, "maxLength": 10},
+				"price": {"type": "number", "exclusiveMinimum": 0}
+			}
+		}
+	}
+}`)
+
+func TestSchemaValidateJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{name: "valid", doc: `{"id": 1, "items": [{"name": "a", "price": 1.5}], "parent": {"id": 2, "items": [{"name": "b"}]}}`},
+		{name: "integer as float", doc: `{"id": 1.0, "items": [{"name": "a"}]}`},
+		{name: "wrong type", doc: `{"id": "1", "items": [{"name": "a"}]}`, want: []string{"/id type"}},
+		{name: "missing", doc: `{"items": []}`, want: []string{"/id required", "/items minItems"}},
+		{name: "nested", doc: `{"id": 1, "items": [{"name": ""}, {"price": 0}]}`, want: []string{"/items/0/name minLength", "/items/1/name required", "/items/1/price exclusiveMinimum"}},
+		{name: "enum and pattern", doc: `{"id": 1, "items": [{"name": "a"}], "status": "new", "email": "nope"}`, want: []string{"/email pattern", "/status enum"}},
+		{name: "additional", doc: `{"id": 1, "items": [{"name": "a"}], "a/b": true}`, want: []string{"/a~1b additionalProperties"}},
+		{name: "recursive", doc: `{"id": 1, "items": [{"name": "a"}], "parent": {"id": 0, "items": [{"name": "b"}]}}`, want: []string{"/parent anyOf"}},
+		{name: "root type", doc: `[]`, want: []string{" type"}},
+	}
+
+	for _, test := range tests {
+		err := orderSchema.ValidateJSON([]byte(test.doc))
+		if len(test.want) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v\n", test.name, err)
+			}
+			continue
+		}
+
+		var errs SchemaErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: expected SchemaErrors, got %v\n", test.name, err)
+			continue
+		}
+		var got []string
+		for _, e := range errs {
+			got = append(got, e.Pointer+" "+e.Keyword)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected %v, got %v\n", test.name, test.want, got)
+		}
+	}
+}
+
+func TestCompileSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{name: "not json", schema: `{`},
+		{name: "not a schema", schema: `42`},
+		{name: "bad type", schema: `{"type": "float"}`},
+		{name: "bad pattern", schema: `{"pattern": "("}`},
+		{name: "bad count", schema: `{"minLength": -1}`},
+		{name: "external ref", schema: `{"$ref": "https://example.com/schema.json"}`},
+		{name: "missing ref", schema: `{"$ref": "#/$defs/nothing"}`},
+		{name: "ref cycle", schema: `{"$defs": {"a": {"allOf": [{"$ref": "#"}]}}, "$ref": "#/$defs/a"}`},
+	}
+
+	for _, test := range tests {
+		if _, err := CompileSchema([]byte(test.schema)); err == nil {
+			t.Errorf("%s: expected an error\n", test.name)
+		}
+	}
+}
+
+func TestSchemaKeywords(t *testing.T) {
+	tests := []struct {
+		schema string
+		doc    string
+		valid  bool
+	}{
+		{schema: `true`, doc: `{"any": 1}`, valid: true},
+		{schema: `false`, doc: `1`, valid: false},
+		{schema: `{"type": ["string", "null"]}`, doc: `null`, valid: true},
+		{schema: `{"const": {"a": [1, 2.0]}}`, doc: `{"a": [1.0, 2]}`, valid: true},
+		{schema: `{"maxItems": 1}`, doc: `[1, 2]`, valid: false},
+		{schema: `{"maxLength": 2}`, doc: `"äö"`, valid: true},
+		{schema: `{"maximum": 10}`, doc: `10`, valid: true},
+		{schema: `{"exclusiveMaximum": 10}`, doc: `10`, valid: false},
+		{schema: `{"allOf": [{"minimum": 1}, {"maximum": 2}]}`, doc: `3`, valid: false},
+		{schema: `{"oneOf": [{"type": "number"}, {"type": "integer"}]}`, doc: `1`, valid: false},
+		{schema: `{"oneOf": [{"type": "number"}, {"type": "integer"}]}`, doc: `1.5`, valid: true},
+		{schema: `{"not": {"type": "string"}}`, doc: `"a"`, valid: false},
+		{schema: `{"additionalProperties": {"type": "integer"}}`, doc: `{"a": 1, "b": "2"}`, valid: false},
+		{schema: `{"$defs": {"a~b": {"type": "string"}}, "$ref": "#/$defs/a~0b"}`, doc: `"x"`, valid: true},
+	}
+
+	for _, test := range tests {
+		schema, err := CompileSchema([]byte(test.schema))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v\n", test.schema, err)
+			continue
+		}
+		if err := schema.ValidateJSON([]byte(test.doc)); (err == nil) != test.valid {
+			t.Errorf("%s with %s: expected valid %v, got %v\n", test.schema, test.doc, test.valid, err)
+		}
+	}
+}
+
+type schemaOrder struct {
+	ID    int `json:"id"`
+	Items []struct {
+		Name  string  `json:"name"`
+		Price float64 `json:"price"`
+	} `json:"items"`
+}
+
+func TestReadJSONSchema(t *testing.T) {
+	schemas := NewSchemaRegistry()
+	schemas.Register(schemaOrder{}, orderSchema)
+	tool := Tools{Schemas: schemas}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "valid", body: `{"id": 7, "items": [{"name": "a", "price": 2}]}`},
+		{name: "invalid", body: `{"id": 0, "items": []}`, status: http.StatusUnprocessableEntity},
+		{name: "syntax", body: `{"id": 7,`, status: http.StatusBadRequest},
+		{name: "two values", body: `{"id": 7, "items": [{"name": "a"}]} {}`, status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+		var order schemaOrder
+		err := tool.ReadJSON(httptest.NewRecorder(), req, &order)
+
+		if test.status == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v\n", test.name, err)
+			} else if order.ID != 7 || len(order.Items) != 1 || order.Items[0].Price != 2 {
+				t.Errorf("%s: unexpected result %+v\n", test.name, order)
+			}
+			continue
+		}
+
+		var statusErr StatusCoder
+		if !errors.As(err, &statusErr) || statusErr.StatusCode() != test.status {
+			t.Errorf("%s: expected error with status %d, got %v\n", test.name, test.status, err)
+		}
+	}
+
+	// Types without a schema are decoded as usual.
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"foo": "bar"}`))
+	var other struct {
+		Foo string `json:"foo"`
+	}
+	if err := tool.ReadJSON(httptest.NewRecorder(), req, &other); err != nil || other.Foo != "bar" {
+		t.Errorf("unexpected result %+v, %v\n", other, err)
+	}
+}
+
+func TestReadJSONArraySchema(t *testing.T) {
+	type item struct {
+		Name  string  `json:"name"`
+		Price float64 `json:"price"`
+	}
+	schema, err := CompileSchema([]byte(`{"type": "array", "items": {"type": "object", "required": ["name", "price"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas := NewSchemaRegistry()
+	schemas.Register([]item{}, schema)
+	tool := Tools{Schemas: schemas}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "valid", body: `[{"name": "a", "price": 2}]`},
+		{name: "invalid", body: `[{"name": "a"}]`, status: http.StatusUnprocessableEntity},
+		{name: "two values", body: `[{"name": "a", "price": 2}] []`, status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		// ReadJSONArray checks the schema like ReadJSON into a slice does.
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+		items, err := ReadJSONArray[item](&tool, httptest.NewRecorder(), req, 10)
+
+		req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+		var slice []item
+		sliceErr := tool.ReadJSON(httptest.NewRecorder(), req, &slice)
+
+		if test.status == 0 {
+			if err != nil || sliceErr != nil {
+				t.Errorf("%s: unexpected errors: %v, %v\n", test.name, err, sliceErr)
+			} else if len(items) != 1 || items[0].Price != 2 {
+				t.Errorf("%s: unexpected result %+v\n", test.name, items)
+			}
+			continue
+		}
+
+		for _, err := range []error{err, sliceErr} {
+			var statusErr StatusCoder
+			if !errors.As(err, &statusErr) || statusErr.StatusCode() != test.status {
+				t.Errorf("%s: expected error with status %d, got %v\n", test.name, test.status, err)
+			}
+		}
+	}
+}
+
+func TestWriteJSONValidateResponses(t *testing.T) {
+	schemas := NewSchemaRegistry()
+	schemas.Register(&schemaOrder{}, orderSchema)
+	tool := Tools{Schemas: schemas, ValidateResponses: true}
+
+	rr := httptest.NewRecorder()
+	err := tool.WriteJSON(rr, http.StatusOK, schemaOrder{ID: 1})
+	var responseErr *ResponseSchemaError
+	if !errors.As(err, &responseErr) {
+		t.Fatalf("expected *ResponseSchemaError, got %v\n", err)
+	}
+	if responseErr.StatusCode() != http.StatusInternalServerError || rr.Body.Len() != 0 {
+		t.Errorf("expected nothing to be written, got %q\n", rr.Body.String())
+	}
+
+	order := &schemaOrder{ID: 1}
+	order.Items = append(order.Items, struct {
+		Name  string  `json:"name"`
+		Price float64 `json:"price"`
+	}{Name: "a", Price: 1})
+	if err := tool.WriteJSON(httptest.NewRecorder(), http.StatusOK, order); err != nil {
+		t.Errorf("unexpected error: %v\n", err)
+	}
+}
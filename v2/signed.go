@@ -0,0 +1,220 @@
+package toolkit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidSignature is returned for signed URLs which are incomplete, tampered with or signed by an unknown key.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrLinkExpired is returned for signed URLs whose expiry has passed.
+	ErrLinkExpired = errors.New("link has expired")
+	// ErrNoSigningKey is returned when signing with a URLSigner that has no key, e.g. the zero value.
+	ErrNoSigningKey = errors.New("URL signer has no signing key")
+)
+
+// SigningKey is a secret used to sign and verify download URLs. ID is sent along with the signature, so
+// that the matching key can be found during verification.
+type SigningKey struct {
+	ID     string
+	Secret []byte
+}
+
+// URLSigner mints and verifies HMAC-SHA256 signed download URLs and pagination cursors. URLs are signed
+// with the current key and verified with any active key, so keys can be rotated without breaking links
+// already handed out. A URLSigner is safe for concurrent use. Create it with NewURLSigner; the zero value
+// has no key, so it rejects all URLs and signing fails with ErrNoSigningKey until a key is set by Rotate.
+type URLSigner struct {
+	mu   sync.RWMutex
+	keys []SigningKey // keys[0] is used for signing
+}
+
+// NewURLSigner creates a URLSigner which signs with current and verifies with current and the optional
+// previous keys.
+func NewURLSigner(current SigningKey, previous ...SigningKey) (*URLSigner, error) {
+	s := &URLSigner{}
+	for _, key := range append([]SigningKey{current}, previous...) {
+		if err := s.addKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Rotate makes key the signing key. The former keys stay active for verification until they are retired.
+func (s *URLSigner) Rotate(key SigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := validateKey(key, s.keys); err != nil {
+		return err
+	}
+	s.keys = append([]SigningKey{key}, s.keys...)
+	return nil
+}
+
+// Retire removes the key with the given ID, so that URLs signed with it are no longer accepted.
+// The current signing key cannot be retired.
+func (s *URLSigner) Retire(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.keys) > 0 && s.keys[0].ID == id {
+		return errors.New("the signing key cannot be retired")
+	}
+	for i, key := range s.keys {
+		if key.ID == id {
+			s.keys = append(s.keys[:i:i], s.keys[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("unknown key")
+}
+
+// SignURL returns baseURL with query parameters for the file path, the display name, the expiry and the
+// signature. Existing query parameters of baseURL are kept but not signed.
+func (s *URLSigner) SignURL(baseURL, file, displayName string, expires time.Time) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := s.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	q := u.Query()
+	q.Set("file", file)
+	q.Set("name", displayName)
+	q.Set("exp", exp)
+	q.Set("kid", key.ID)
+	q.Set("sig", base64.RawURLEncoding.EncodeToString(signature(key, file, displayName, exp)))
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Verify checks the signature and the expiry of a URL created by SignURL and returns the file path and
+// the display name. The error is ErrInvalidSignature or ErrLinkExpired.
+func (s *URLSigner) Verify(u *url.URL) (file, displayName string, err error) {
+	q := u.Query()
+	file, displayName, exp := q.Get("file"), q.Get("name"), q.Get("exp")
+
+	sig, err := base64.RawURLEncoding.DecodeString(q.Get("sig"))
+	if err != nil || file == "" {
+		return "", "", ErrInvalidSignature
+	}
+
+	key, ok := s.key(q.Get("kid"))
+	if !ok {
+		return "", "", ErrInvalidSignature
+	}
+	if !hmac.Equal(sig, signature(key, file, displayName, exp)) {
+		return "", "", ErrInvalidSignature
+	}
+
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return "", "", ErrInvalidSignature
+	}
+	if time.Now().Unix() > expires {
+		return "", "", ErrLinkExpired
+	}
+
+	return file, displayName, nil
+}
+
+// signingKey returns the key new URLs and cursors are signed with.
+func (s *URLSigner) signingKey() (SigningKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.keys) == 0 {
+		return SigningKey{}, ErrNoSigningKey
+	}
+	return s.keys[0], nil
+}
+
+// key returns the active key with the given ID.
+func (s *URLSigner) key(id string) (SigningKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+// addKey appends key to the active keys.
+func (s *URLSigner) addKey(key SigningKey) error {
+	if err := validateKey(key, s.keys); err != nil {
+		return err
+	}
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+// validateKey checks that key has an ID and a secret and that its ID isn't used by one of keys.
+func validateKey(key SigningKey, keys []SigningKey) error {
+	if key.ID == "" || len(key.Secret) == 0 {
+		return errors.New("signing key needs an ID and a secret")
+	}
+	for _, k := range keys {
+		if k.ID == key.ID {
+			return errors.New("duplicate signing key ID " + key.ID)
+		}
+	}
+	return nil
+}
+
+// signature returns the HMAC of the signed URL parameters. Each part is prefixed by its length, so that
+// the boundaries between the parts cannot be shifted.
+func signature(key SigningKey, file, displayName, exp string) []byte {
+	return signParts(key, file, displayName, exp)
+}
+
+// signParts returns the HMAC of the key ID and parts, each prefixed by its length.
+func signParts(key SigningKey, parts ...string) []byte {
+	mac := hmac.New(sha256.New, key.Secret)
+	for _, part := range append([]string{key.ID}, parts...) {
+		mac.Write([]byte(strconv.Itoa(len(part))))
+		mac.Write([]byte{':'})
+		mac.Write([]byte(part))
+	}
+	return mac.Sum(nil)
+}
+
+// SignedDownloadHandler returns a handler which serves URLs minted by signer.SignURL. After the signature
+// has been verified, the file is served by DownloadStaticFileFromRoot, so DownloadRoot is required and
+// Disposition and all other download settings apply; directories are answered with 404 Not Found and
+// requests without DownloadRoot with 500. Invalid signatures are answered with 403 Forbidden, expired
+// links with 410 Gone.
+func (t *Tools) SignedDownloadHandler(signer *URLSigner) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, displayName, err := signer.Verify(r.URL)
+		switch {
+		case errors.Is(err, ErrLinkExpired):
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err := t.DownloadStaticFileFromRoot(w, r, file, displayName); err != nil {
+			writeDownloadError(w, err)
+		}
+	})
+}
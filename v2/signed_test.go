@@ -0,0 +1,153 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSigner(t *testing.T) {
+	oldKey := SigningKey{ID: "k1", Secret: []byte("old secret")}
+	newKey := SigningKey{ID: "k2", Secret: []byte("new secret")}
+
+	signer, err := NewURLSigner(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedOld, err := signer.SignURL("https://example.com/dl?x=1", "docs/report.pdf", "Report 2023.pdf", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := signer.Rotate(newKey); err != nil {
+		t.Fatal(err)
+	}
+	signedNew, _ := signer.SignURL("https://example.com/dl", "docs/report.pdf", "Report 2023.pdf", time.Now().Add(time.Hour))
+	expired, _ := signer.SignURL("https://example.com/dl", "docs/report.pdf", "report.pdf", time.Now().Add(-time.Minute))
+
+	tests := []struct {
+		name   string
+		rawURL string
+		err    error
+	}{
+		{name: "signed with current key", rawURL: signedNew},
+		{name: "signed with previous key", rawURL: signedOld},
+		{name: "expired", rawURL: expired, err: ErrLinkExpired},
+		{name: "tampered file", rawURL: strings.Replace(signedNew, "report.pdf", "secret.pdf", 1), err: ErrInvalidSignature},
+		{name: "tampered expiry", rawURL: strings.Replace(expired, "exp=", "exp=9", 1), err: ErrInvalidSignature},
+		{name: "unknown key", rawURL: strings.Replace(signedNew, "kid=k2", "kid=k3", 1), err: ErrInvalidSignature},
+		{name: "unsigned", rawURL: "https://example.com/dl?file=a.txt", err: ErrInvalidSignature},
+	}
+
+	for _, test := range tests {
+		u, err := url.Parse(test.rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, name, err := signer.Verify(u)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected error %v, got %v\n", test.name, test.err, err)
+		}
+		if test.err == nil && (file != "docs/report.pdf" || name != "Report 2023.pdf") {
+			t.Errorf("%s: unexpected file %q and name %q\n", test.name, file, name)
+		}
+	}
+
+	if err := signer.Retire("k1"); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(signedOld)
+	if _, _, err := signer.Verify(u); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("URL signed with retired key accepted: %v\n", err)
+	}
+	if err := signer.Retire("k2"); err == nil {
+		t.Error("Expected error retiring the signing key")
+	}
+	if _, err := NewURLSigner(SigningKey{ID: "k"}); err == nil {
+		t.Error("Expected error for key without secret")
+	}
+}
+
+func TestSignedDownloadHandler(t *testing.T) {
+	root := setupDownloadRoot(t)
+	tools := Tools{DownloadRoot: root}
+	signer, _ := NewURLSigner(SigningKey{ID: "k1", Secret: []byte("secret")})
+	handler := tools.SignedDownloadHandler(signer)
+
+	valid, _ := signer.SignURL("/download", "docs/a.txt", "a.txt", time.Now().Add(time.Hour))
+	outside, _ := signer.SignURL("/download", "../secret/p.txt", "p.txt", time.Now().Add(time.Hour))
+	expired, _ := signer.SignURL("/download", "docs/a.txt", "a.txt", time.Now().Add(-time.Hour))
+	directory, _ := signer.SignURL("/download", "docs", "docs", time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+	}{
+		{name: "valid", url: valid, status: http.StatusOK},
+		{name: "outside root", url: outside, status: http.StatusForbidden},
+		{name: "expired", url: expired, status: http.StatusGone},
+		{name: "tampered", url: strings.Replace(valid, "a.txt", "b.txt", 1), status: http.StatusForbidden},
+		{name: "directory", url: directory, status: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", test.url, nil))
+		if rr.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, test.status, rr.Code)
+		}
+	}
+}
+
+func TestSignedDownloadHandlerWithoutRoot(t *testing.T) {
+	var tools Tools
+	signer, _ := NewURLSigner(SigningKey{ID: "k1", Secret: []byte("secret")})
+	handler := tools.SignedDownloadHandler(signer)
+
+	for _, file := range []string{".", "signed_test.go"} {
+		signed, _ := signer.SignURL("/download", file, "download", time.Now().Add(time.Hour))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", signed, nil))
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("%s: expected status %d without download root, got %d\n", file, http.StatusInternalServerError, rr.Code)
+		}
+	}
+}
+
+func TestURLSignerZeroValue(t *testing.T) {
+	var signer URLSigner
+
+	if _, err := signer.SignURL("/download", "a.txt", "a.txt", time.Now().Add(time.Hour)); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("SignURL: expected ErrNoSigningKey, got %v\n", err)
+	}
+	if _, err := signer.SignCursor([]byte("1")); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("SignCursor: expected ErrNoSigningKey, got %v\n", err)
+	}
+	u, _ := url.Parse("/download?file=a.txt&exp=9999999999&kid=&sig=")
+	if _, _, err := signer.Verify(u); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify: expected ErrInvalidSignature, got %v\n", err)
+	}
+	if _, err := signer.VerifyCursor("e30..."); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("VerifyCursor: expected ErrInvalidCursor, got %v\n", err)
+	}
+	if err := signer.Retire("k1"); err == nil {
+		t.Error("Retire: expected error for unknown key")
+	}
+
+	if err := signer.Rotate(SigningKey{ID: "k1", Secret: []byte("secret")}); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := signer.SignURL("/download", "a.txt", "a.txt", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ = url.Parse(signed)
+	if file, _, err := signer.Verify(u); err != nil || file != "a.txt" {
+		t.Errorf("expected the rotated key to work, got %q and %v\n", file, err)
+	}
+}
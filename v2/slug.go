@@ -0,0 +1,180 @@
+package toolkit
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// slugWordRegexp matches the words an ASCII slug is built from.
+var slugWordRegexp = regexp.MustCompile(`[a-zA-Z\d]+`)
+
+// slugUnicodeWordRegexp matches the words a Unicode slug is built from.
+var slugUnicodeWordRegexp = regexp.MustCompile(`[\p{L}\p{M}\p{N}]+`)
+
+// EnglishStopWords is a list of common English words that can be passed to SlugOptions.StopWords.
+var EnglishStopWords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "from", "in", "into",
+	"is", "it", "of", "on", "or", "that", "the", "to", "was", "with",
+}
+
+// SlugOptions configures SlugifyWithOptions. The zero value creates the same slugs as Slugify.
+type SlugOptions struct {
+	// Separator is put between the words of the slug. The default is a dash.
+	Separator string
+	// MaxLength is the maximum length of the slug in bytes. The slug is truncated on a word boundary;
+	// only a single word longer than MaxLength is cut. Zero means no limit.
+	MaxLength int
+	// StopWords are removed from the slug, compared case insensitive. If a slug would consist of stop
+	// words only, they are kept.
+	StopWords []string
+	// PreserveCase keeps upper case letters instead of lower casing the slug.
+	PreserveCase bool
+	// PreserveUnicode keeps Unicode letters and digits instead of transliterating them to ASCII.
+	PreserveUnicode bool
+	// Language is the language tag whose transliteration and case rules are applied. The default is
+	// the Tools' Language.
+	Language string
+	// Replacements are applied before anything else, e.g. "&" to "and". The replacement becomes
+	// a word of its own. Longer keys take precedence over shorter ones.
+	Replacements map[string]string
+}
+
+// Slugify is a simple function that creates a slug from a string or that returns an error
+// if the slug cannot be created due to an invalid input. The string is transliterated to ASCII
+// first (see Transliterate), then characters not in [a-z] or [0-9] are replaced by a dash.
+// If PreserveUnicodeSlugs is set, Unicode letters and digits are kept instead. The slug neither
+// begins nor ends with a dash.
+func (t *Tools) Slugify(s string) (string, error) {
+	return t.SlugifyWithOptions(s, SlugOptions{PreserveUnicode: t.PreserveUnicodeSlugs})
+}
+
+// SlugifyWithOptions creates a slug from a string like Slugify, but lets the caller choose the
+// separator, a maximum length, stop words, case preservation and replacements. See SlugOptions.
+func (t *Tools) SlugifyWithOptions(s string, opts SlugOptions) (string, error) {
+	if s == "" {
+		return "", errors.New("empty string not permitted")
+	}
+
+	separator := "-"
+	if opts.Separator != "" {
+		separator = opts.Separator
+	}
+
+	s = applyReplacements(s, opts.Replacements)
+
+	lang := t.Language
+	if opts.Language != "" {
+		lang = opts.Language
+	}
+	rules := rulesFor(lang)
+
+	// Lower case first, so that language specific case mappings like the Turkish dotless i apply.
+	if !opts.PreserveCase {
+		s = rules.toLower(s)
+	}
+	wordRegexp := slugUnicodeWordRegexp
+	if !opts.PreserveUnicode {
+		s = transliterate(s, rules)
+		wordRegexp = slugWordRegexp
+	}
+
+	words := removeStopWords(wordRegexp.FindAllString(s, -1), opts.StopWords)
+
+	result := joinWords(words, separator, opts.MaxLength)
+	if len(result) == 0 {
+		return "", errors.New("after trimming, slug is of zero length")
+	}
+	return result, nil
+}
+
+// applyReplacements replaces every key of replacements in s by its value surrounded by blanks.
+func applyReplacements(s string, replacements map[string]string) string {
+	if len(replacements) == 0 {
+		return s
+	}
+
+	keys := make([]string, 0, len(replacements))
+	for k := range replacements {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	oldnew := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		oldnew = append(oldnew, k, " "+replacements[k]+" ")
+	}
+	return strings.NewReplacer(oldnew...).Replace(s)
+}
+
+// removeStopWords returns words without the stop words. If only stop words are found, words is returned.
+func removeStopWords(words, stopWords []string) []string {
+	if len(stopWords) == 0 {
+		return words
+	}
+
+	var result []string
+	for _, w := range words {
+		stop := false
+		for _, sw := range stopWords {
+			if strings.EqualFold(w, sw) {
+				stop = true
+				break
+			}
+		}
+		if !stop {
+			result = append(result, w)
+		}
+	}
+	if len(result) == 0 {
+		return words
+	}
+	return result
+}
+
+// joinWords joins words with separator, dropping trailing words that would exceed maxLength.
+func joinWords(words []string, separator string, maxLength int) string {
+	if maxLength <= 0 {
+		return strings.Join(words, separator)
+	}
+
+	var b strings.Builder
+	for _, w := range words {
+		if b.Len() == 0 {
+			if len(w) > maxLength {
+				return truncateRunes(w, maxLength)
+			}
+			b.WriteString(w)
+			continue
+		}
+		if b.Len()+len(separator)+len(w) > maxLength {
+			break
+		}
+		b.WriteString(separator)
+		b.WriteString(w)
+	}
+	return b.String()
+}
+
+// truncateRunes cuts s to at most n bytes without splitting a multi-byte character.
+func truncateRunes(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,162 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ErrSlugTaken is returned by SlugRegistry.Set if the slug belongs to another entity.
+var ErrSlugTaken = errors.New("slug is used by another entity")
+
+// SlugRegistry keeps track of the current slug of each entity and all slugs it had before, so that old
+// URLs still resolve after a title change. It is safe for concurrent use. A SlugRegistry implements
+// SlugChecker, so UniqueSlug never hands out a slug that is or was in use.
+type SlugRegistry struct {
+	mu      sync.RWMutex
+	current map[string]string   // entity ID -> current slug
+	history map[string][]string // entity ID -> previous slugs, oldest first
+	owners  map[string]string   // slug -> entity ID
+}
+
+// NewSlugRegistry creates an empty SlugRegistry.
+func NewSlugRegistry() *SlugRegistry {
+	return &SlugRegistry{
+		current: make(map[string]string),
+		history: make(map[string][]string),
+		owners:  make(map[string]string),
+	}
+}
+
+// Set makes slug the current slug of the entity. The previous slug is kept in the entity's history.
+// Setting a slug out of the entity's history makes it current again.
+func (reg *SlugRegistry) Set(entityID, slug string) error {
+	if slug == "" {
+		return errors.New("empty slug not permitted")
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if owner, ok := reg.owners[slug]; ok && owner != entityID {
+		return ErrSlugTaken
+	}
+
+	old, ok := reg.current[entityID]
+	if ok && old == slug {
+		return nil
+	}
+
+	history := reg.history[entityID]
+	for i, s := range history {
+		if s == slug {
+			history = append(history[:i:i], history[i+1:]...)
+			break
+		}
+	}
+	if ok {
+		history = append(history, old)
+	}
+	reg.history[entityID] = history
+	reg.current[entityID] = slug
+	reg.owners[slug] = entityID
+
+	return nil
+}
+
+// Current returns the current slug of the entity.
+func (reg *SlugRegistry) Current(entityID string) (string, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	slug, ok := reg.current[entityID]
+	return slug, ok
+}
+
+// History returns the previous slugs of the entity, oldest first.
+func (reg *SlugRegistry) History(entityID string) []string {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	return append([]string(nil), reg.history[entityID]...)
+}
+
+// Resolve returns the entity a current or previous slug belongs to and the entity's current slug.
+func (reg *SlugRegistry) Resolve(slug string) (entityID, current string, ok bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	entityID, ok = reg.owners[slug]
+	if !ok {
+		return "", "", false
+	}
+	return entityID, reg.current[entityID], true
+}
+
+// Remove forgets the entity together with its current and previous slugs.
+func (reg *SlugRegistry) Remove(entityID string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if slug, ok := reg.current[entityID]; ok {
+		delete(reg.owners, slug)
+	}
+	for _, slug := range reg.history[entityID] {
+		delete(reg.owners, slug)
+	}
+	delete(reg.current, entityID)
+	delete(reg.history, entityID)
+}
+
+// SlugExists reports whether slug is the current or a previous slug of any entity.
+func (reg *SlugRegistry) SlugExists(slug string) (bool, error) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	_, ok := reg.owners[slug]
+	return ok, nil
+}
+
+// RedirectMiddleware redirects requests for previous slugs to the canonical URL with the current slug.
+// The slug is expected to be the path segment right after prefix, e.g. "/articles/" for URLs like
+// "/articles/my-title/comments". GET and HEAD requests get a 301 (Moved Permanently), other methods
+// a 308 (Permanent Redirect) so that the method and body are kept. All other requests are passed on.
+func (reg *SlugRegistry) RedirectMiddleware(prefix string) func(http.Handler) http.Handler {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			slug, rest := r.URL.Path[len(prefix):], ""
+			if i := strings.IndexByte(slug, '/'); i >= 0 {
+				slug, rest = slug[:i], slug[i:]
+			}
+
+			_, current, ok := reg.Resolve(slug)
+			if !ok || current == slug {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The path is decoded; escape it again so that e.g. "?" and non-ASCII slugs survive.
+			target := (&url.URL{Path: prefix + current + rest}).EscapedPath()
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+
+			code := http.StatusMovedPermanently
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				code = http.StatusPermanentRedirect
+			}
+			http.Redirect(w, r, target, code)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSlugRegistry(t *testing.T) {
+	reg := NewSlugRegistry()
+
+	for _, slug := range []string{"first-title", "second-title", "third-title"} {
+		if err := reg.Set("42", slug); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current, ok := reg.Current("42")
+	if !ok || current != "third-title" {
+		t.Errorf("Expected current slug third-title, got %s\n", current)
+	}
+
+	want := []string{"first-title", "second-title"}
+	if got := reg.History("42"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected history %v, got %v\n", want, got)
+	}
+
+	id, current, ok := reg.Resolve("first-title")
+	if !ok || id != "42" || current != "third-title" {
+		t.Errorf("Resolve returned %s, %s, %v\n", id, current, ok)
+	}
+
+	if _, _, ok := reg.Resolve("unknown"); ok {
+		t.Error("Unknown slug resolved")
+	}
+
+	if err := reg.Set("43", "first-title"); !errors.Is(err, ErrSlugTaken) {
+		t.Errorf("Expected ErrSlugTaken, got %v\n", err)
+	}
+
+	// Going back to an old slug makes it current and removes it from the history.
+	if err := reg.Set("42", "first-title"); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"second-title", "third-title"}
+	if got := reg.History("42"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected history %v, got %v\n", want, got)
+	}
+
+	exists, _ := reg.SlugExists("second-title")
+	if !exists {
+		t.Error("Previous slug should exist")
+	}
+
+	reg.Remove("42")
+	if _, _, ok := reg.Resolve("second-title"); ok {
+		t.Error("Slug of removed entity still resolves")
+	}
+}
+
+func TestSlugRegistryRedirectMiddleware(t *testing.T) {
+	reg := NewSlugRegistry()
+	reg.Set("1", "old-title")
+	reg.Set("1", "new-title")
+	reg.Set("2", "café-old")
+	reg.Set("2", "café-new")
+
+	handler := reg.RedirectMiddleware("/articles")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		status   int
+		location string
+	}{
+		{name: "current slug", method: "GET", url: "/articles/new-title", status: http.StatusOK},
+		{name: "old slug", method: "GET", url: "/articles/old-title", status: http.StatusMovedPermanently, location: "/articles/new-title"},
+		{name: "old slug with rest", method: "GET", url: "/articles/old-title/comments?page=2", status: http.StatusMovedPermanently, location: "/articles/new-title/comments?page=2"},
+		{name: "escaped rest", method: "GET", url: "/articles/old-title/a%3Fb%20c?page=2", status: http.StatusMovedPermanently, location: "/articles/new-title/a%3Fb%20c?page=2"},
+		{name: "unicode slug", method: "GET", url: "/articles/caf%C3%A9-old", status: http.StatusMovedPermanently, location: "/articles/caf%C3%A9-new"},
+		{name: "old slug post", method: "POST", url: "/articles/old-title", status: http.StatusPermanentRedirect, location: "/articles/new-title"},
+		{name: "unknown slug", method: "GET", url: "/articles/unknown", status: http.StatusOK},
+		{name: "other path", method: "GET", url: "/users/old-title", status: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(test.method, test.url, nil))
+
+		if rr.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, test.status, rr.Code)
+		}
+		if got := rr.Header().Get("Location"); got != test.location {
+			t.Errorf("%s: expected location %q, got %q\n", test.name, test.location, got)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package toolkit
+
+import "testing"
+
+func TestSlugifyWithOptions(t *testing.T) {
+	tools := Tools{}
+
+	tests := []struct {
+		name     string
+		sentence string
+		opts     SlugOptions
+		slug     string
+		expErr   bool
+	}{
+		{name: "defaults", sentence: "Hello World!", opts: SlugOptions{}, slug: "hello-world"},
+		{name: "separator", sentence: "Hello World!", opts: SlugOptions{Separator: "_"}, slug: "hello_world"},
+		{name: "max length", sentence: "The quick brown fox", opts: SlugOptions{MaxLength: 12}, slug: "the-quick"},
+		{name: "max length exact", sentence: "The quick brown fox", opts: SlugOptions{MaxLength: 15}, slug: "the-quick-brown"},
+		{name: "long first word", sentence: "Supercalifragilistic day", opts: SlugOptions{MaxLength: 5}, slug: "super"},
+		{name: "long first word unicode", sentence: "Привет", opts: SlugOptions{MaxLength: 5, PreserveUnicode: true}, slug: "пр"},
+		{name: "stop words", sentence: "The Lord of the Rings", opts: SlugOptions{StopWords: EnglishStopWords}, slug: "lord-rings"},
+		{name: "only stop words", sentence: "The And", opts: SlugOptions{StopWords: EnglishStopWords}, slug: "the-and"},
+		{name: "preserve case", sentence: "Hello World", opts: SlugOptions{PreserveCase: true}, slug: "Hello-World"},
+		{name: "preserve case translit", sentence: "Ärger", opts: SlugOptions{PreserveCase: true}, slug: "Arger"},
+		{
+			name:     "replacements",
+			sentence: "Tom&Jerry @ home",
+			opts:     SlugOptions{Replacements: map[string]string{"&": "and", "@": "at"}},
+			slug:     "tom-and-jerry-at-home",
+		},
+		{
+			name:     "longest replacement first",
+			sentence: "A && B & C",
+			opts:     SlugOptions{Replacements: map[string]string{"&": "and", "&&": "andand"}},
+			slug:     "a-andand-b-and-c",
+		},
+		{name: "empty", sentence: "", opts: SlugOptions{}, expErr: true},
+		{name: "nothing left", sentence: "!?", opts: SlugOptions{Separator: "_"}, expErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := tools.SlugifyWithOptions(test.sentence, test.opts)
+		if err != nil && !test.expErr {
+			t.Errorf("%s - received error %v\n", test.name, err)
+		}
+		if err == nil && test.expErr {
+			t.Errorf("%s - expected error but received none", test.name)
+		}
+		if err == nil && got != test.slug {
+			t.Errorf("%s - expected %s, got %s\n", test.name, test.slug, got)
+		}
+	}
+}
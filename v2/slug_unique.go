@@ -0,0 +1,211 @@
+package toolkit
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrSlugUnavailable is returned by UniqueSlug if no free slug was found within the permitted attempts.
+var ErrSlugUnavailable = errors.New("no unique slug available")
+
+// ErrSlugTooLong is returned by UniqueSlug if MaxLength leaves no room for the slug next to its suffix.
+var ErrSlugTooLong = errors.New("MaxLength is too small for slug and suffix")
+
+// slugSuffixSource contains the characters random slug suffixes are built from.
+const slugSuffixSource = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// SlugChecker reports whether a slug is already taken, e.g. by looking it up in a database.
+type SlugChecker interface {
+	SlugExists(slug string) (bool, error)
+}
+
+// SlugCheckerFunc is an adapter to use an ordinary function as SlugChecker.
+type SlugCheckerFunc func(slug string) (bool, error)
+
+// SlugExists calls f(slug).
+func (f SlugCheckerFunc) SlugExists(slug string) (bool, error) {
+	return f(slug)
+}
+
+// SlugReserver is implemented by slug checkers that can check and claim a slug in one atomic step.
+// UniqueSlug prefers Reserve over SlugExists if the checker implements it.
+type SlugReserver interface {
+	Reserve(slug string) (bool, error)
+}
+
+// UniqueSlugOptions configures UniqueSlug.
+type UniqueSlugOptions struct {
+	SlugOptions
+
+	// MaxAttempts limits the number of candidates that are checked. The default is 100.
+	MaxAttempts int
+	// RandomSuffix appends random characters instead of an incrementing number (-2, -3, ...).
+	RandomSuffix bool
+	// RandomSuffixLength is the number of random characters. The default is 6.
+	RandomSuffixLength int
+}
+
+// UniqueSlug creates a slug from s like SlugifyWithOptions and makes it unique by asking checker whether
+// it is taken. Taken slugs get a suffix, either an incrementing number or random characters. If a
+// MaxLength is given, the slug is shortened so that slug and suffix fit into it.
+func (t *Tools) UniqueSlug(s string, checker SlugChecker, opts UniqueSlugOptions) (string, error) {
+	base, err := t.SlugifyWithOptions(s, opts.SlugOptions)
+	if err != nil {
+		return "", err
+	}
+
+	maxAttempts := 100
+	if opts.MaxAttempts > 0 {
+		maxAttempts = opts.MaxAttempts
+	}
+	separator := "-"
+	if opts.Separator != "" {
+		separator = opts.Separator
+	}
+	suffixLength := 6
+	if opts.RandomSuffixLength > 0 {
+		suffixLength = opts.RandomSuffixLength
+	}
+
+	candidate := base
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			var suffix string
+			if opts.RandomSuffix {
+				suffix, err = randomSlugSuffix(suffixLength)
+				if err != nil {
+					return "", err
+				}
+			} else {
+				suffix = strconv.Itoa(attempt)
+			}
+			if candidate, err = appendSlugSuffix(base, separator+suffix, separator, opts.MaxLength); err != nil {
+				return "", err
+			}
+		}
+
+		free, err := claimSlug(checker, candidate)
+		if err != nil {
+			return "", err
+		}
+		if free {
+			return candidate, nil
+		}
+	}
+
+	return "", ErrSlugUnavailable
+}
+
+// claimSlug reserves slug if checker supports it, otherwise it checks whether slug is still free.
+func claimSlug(checker SlugChecker, slug string) (bool, error) {
+	if reserver, ok := checker.(SlugReserver); ok {
+		return reserver.Reserve(slug)
+	}
+	exists, err := checker.SlugExists(slug)
+	return !exists, err
+}
+
+// appendSlugSuffix appends suffix to base, shortening base if both wouldn't fit into maxLength. It
+// returns ErrSlugTooLong if nothing of base would be left.
+func appendSlugSuffix(base, suffix, separator string, maxLength int) (string, error) {
+	if maxLength > 0 && len(base)+len(suffix) > maxLength {
+		if maxLength <= len(suffix) {
+			return "", ErrSlugTooLong
+		}
+		base = trimSlugSeparators(base, truncateRunes(base, maxLength-len(suffix)), separator)
+		if base == "" {
+			return "", ErrSlugTooLong
+		}
+	}
+	return base + suffix, nil
+}
+
+// trimSlugSeparators removes the separators at the end of truncated, which is a prefix of base. Only
+// whole separators are removed, plus the beginning of one the truncation cut in half.
+func trimSlugSeparators(base, truncated, separator string) string {
+	for k := len(separator) - 1; k > 0; k-- {
+		if strings.HasSuffix(truncated, separator[:k]) && strings.HasPrefix(base[len(truncated)-k:], separator) {
+			truncated = truncated[:len(truncated)-k]
+			break
+		}
+	}
+	for strings.HasSuffix(truncated, separator) {
+		truncated = strings.TrimSuffix(truncated, separator)
+	}
+	return truncated
+}
+
+// randomSlugSuffix returns length random characters out of slugSuffixSource.
+func randomSlugSuffix(length int) (string, error) {
+	b := make([]byte, length)
+	max := big.NewInt(int64(len(slugSuffixSource)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = slugSuffixSource[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// SlugStore is a concurrency-safe, in-memory set of reserved slugs. It is meant for batch imports
+// where several slugs are created before any of them is persisted. A SlugStore can be passed to
+// UniqueSlug directly.
+type SlugStore struct {
+	mu      sync.Mutex
+	slugs   map[string]struct{}
+	checker SlugChecker
+}
+
+// NewSlugStore creates a SlugStore. The optional checker is consulted for slugs which aren't reserved
+// in the store, so that slugs persisted elsewhere are treated as taken, too.
+func NewSlugStore(checker ...SlugChecker) *SlugStore {
+	s := &SlugStore{slugs: make(map[string]struct{})}
+	if len(checker) > 0 {
+		s.checker = checker[0]
+	}
+	return s
+}
+
+// SlugExists reports whether slug is reserved in the store or taken according to the store's checker.
+func (s *SlugStore) SlugExists(slug string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exists(slug)
+}
+
+// Reserve claims slug and returns true, if it is neither reserved nor taken according to the store's checker.
+func (s *SlugStore) Reserve(slug string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exists, err := s.exists(slug)
+	if err != nil || exists {
+		return false, err
+	}
+	s.slugs[slug] = struct{}{}
+	return true, nil
+}
+
+// Release removes the reservation of slug, e.g. if the import of the entity failed.
+func (s *SlugStore) Release(slug string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.slugs, slug)
+}
+
+// exists must be called with s.mu held.
+func (s *SlugStore) exists(slug string) (bool, error) {
+	if _, ok := s.slugs[slug]; ok {
+		return true, nil
+	}
+	if s.checker != nil {
+		return s.checker.SlugExists(slug)
+	}
+	return false, nil
+}
@@ -0,0 +1,162 @@
+package toolkit
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUniqueSlug(t *testing.T) {
+	tools := Tools{}
+
+	taken := map[string]bool{
+		"hello-world":   true,
+		"hello-world-2": true,
+		"a-long-title":  true,
+		"ab_-cd_-ef":    true,
+		"tax.xmax.xfax": true,
+		"tax.xmax.xfa":  true,
+	}
+	checker := SlugCheckerFunc(func(slug string) (bool, error) {
+		return taken[slug], nil
+	})
+
+	tests := []struct {
+		name     string
+		sentence string
+		opts     UniqueSlugOptions
+		slug     string
+		expErr   bool
+	}{
+		{name: "free", sentence: "Something new", slug: "something-new"},
+		{name: "numbered", sentence: "Hello World", slug: "hello-world-3"},
+		{name: "length limit", sentence: "A long title", opts: UniqueSlugOptions{SlugOptions: SlugOptions{MaxLength: 12}}, slug: "a-long-tit-2"},
+		{name: "multi-character separator", sentence: "Tax max fax", opts: UniqueSlugOptions{SlugOptions: SlugOptions{Separator: ".x", MaxLength: 13}}, slug: "tax.xmax.x2"},
+		{name: "separator cut in half", sentence: "Tax max fa", opts: UniqueSlugOptions{SlugOptions: SlugOptions{Separator: ".x", MaxLength: 12}}, slug: "tax.xmax.x2"},
+		{name: "two character separator cut in half", sentence: "ab cd ef", opts: UniqueSlugOptions{SlugOptions: SlugOptions{Separator: "_-", MaxLength: 10}}, slug: "ab_-cd_-2"},
+		{name: "exhausted", sentence: "Hello World", opts: UniqueSlugOptions{MaxAttempts: 2}, expErr: true},
+		{name: "invalid", sentence: "", expErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := tools.UniqueSlug(test.sentence, checker, test.opts)
+		if err != nil && !test.expErr {
+			t.Errorf("%s - received error %v\n", test.name, err)
+		}
+		if err == nil && test.expErr {
+			t.Errorf("%s - expected error but received none", test.name)
+		}
+		if err == nil && got != test.slug {
+			t.Errorf("%s - expected %s, got %s\n", test.name, test.slug, got)
+		}
+	}
+}
+
+func TestUniqueSlugRandomSuffix(t *testing.T) {
+	tools := Tools{}
+	checker := SlugCheckerFunc(func(slug string) (bool, error) {
+		return slug == "hello-world", nil
+	})
+
+	got, err := tools.UniqueSlug("Hello World", checker, UniqueSlugOptions{RandomSuffix: true, RandomSuffixLength: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "hello-world-") || len(got) != len("hello-world-")+4 {
+		t.Errorf("Unexpected slug %s\n", got)
+	}
+}
+
+func TestUniqueSlugMaxLengthTooSmall(t *testing.T) {
+	tools := Tools{}
+	// Only the slug without suffix is taken.
+	first := ""
+	checker := SlugCheckerFunc(func(slug string) (bool, error) {
+		if first == "" {
+			first = slug
+		}
+		return slug == first, nil
+	})
+
+	tests := []struct {
+		name string
+		opts UniqueSlugOptions
+	}{
+		{name: "random suffix", opts: UniqueSlugOptions{SlugOptions: SlugOptions{MaxLength: 5}, RandomSuffix: true}},
+		{name: "numbered", opts: UniqueSlugOptions{SlugOptions: SlugOptions{MaxLength: 2}}},
+	}
+
+	for _, test := range tests {
+		first = ""
+		got, err := tools.UniqueSlug("Hello World", checker, test.opts)
+		if !errors.Is(err, ErrSlugTooLong) {
+			t.Errorf("%s - expected ErrSlugTooLong, got %q and %v\n", test.name, got, err)
+		}
+	}
+
+	// A limit leaving room for a single character still works.
+	first = ""
+	got, err := tools.UniqueSlug("Hello World", checker, UniqueSlugOptions{SlugOptions: SlugOptions{MaxLength: 3}})
+	if err != nil || got != "h-2" {
+		t.Errorf("expected h-2, got %q and %v\n", got, err)
+	}
+}
+
+func TestUniqueSlugCheckerError(t *testing.T) {
+	tools := Tools{}
+	checkErr := errors.New("database down")
+	checker := SlugCheckerFunc(func(slug string) (bool, error) {
+		return false, checkErr
+	})
+
+	_, err := tools.UniqueSlug("Hello World", checker, UniqueSlugOptions{})
+	if !errors.Is(err, checkErr) {
+		t.Errorf("Expected checker error, got %v\n", err)
+	}
+}
+
+func TestSlugStoreConcurrent(t *testing.T) {
+	tools := Tools{}
+	store := NewSlugStore(SlugCheckerFunc(func(slug string) (bool, error) {
+		return slug == "title", nil
+	}))
+
+	n := 50
+	results := make(chan string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slug, err := tools.UniqueSlug("Title", store, UniqueSlugOptions{})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results <- slug
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	seen := make(map[string]bool)
+	for slug := range results {
+		if seen[slug] {
+			t.Errorf("Slug %s was handed out twice\n", slug)
+		}
+		if slug == "title" {
+			t.Error("Slug taken according to the checker was handed out")
+		}
+		seen[slug] = true
+	}
+	if len(seen) != n {
+		t.Errorf("Expected %d slugs, got %d\n", n, len(seen))
+	}
+
+	store.Release("title-2")
+	exists, _ := store.SlugExists("title-2")
+	if exists {
+		t.Error("Released slug still reserved")
+	}
+}
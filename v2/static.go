@@ -0,0 +1,220 @@
+package toolkit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// precompressed is a content coding ServeStaticFile looks for together with the file extension of the
+// precompressed siblings.
+type precompressed struct {
+	coding string
+	ext    string
+}
+
+// precompressedEncodings are the supported precompressed codings. The order decides between codings
+// the client weighs equally.
+var precompressedEncodings = []precompressed{
+	{coding: "br", ext: ".br"},
+	{coding: "gzip", ext: ".gz"},
+}
+
+// minGzipSize is the minimum size of files ServeStaticFile compresses on the fly.
+const minGzipSize = 1024
+
+// ServeStaticFile serves the file name out of fsys for display in the browser, e.g. assets of a web
+// application; use os.DirFS for files on disk. If the client's Accept-Encoding permits it, a precompressed
+// sibling ("app.js.br" or "app.js.gz" next to "app.js") is served instead of the file. Otherwise files of
+// compressible media types are gzip compressed on the fly, unless the request asks for a range, which is
+// always served from the uncompressed file. Vary: Accept-Encoding is set on all responses.
+// Errors are handled like for DownloadFromFS.
+func (t *Tools) ServeStaticFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) error {
+	name = strings.TrimPrefix(name, "/")
+	if !fs.ValidPath(name) {
+		return &DownloadError{Name: name, Status: http.StatusForbidden, Err: ErrOutsideDownloadRoot}
+	}
+	if !t.extensionAllowed(name) {
+		return &DownloadError{Name: name, Status: http.StatusForbidden, Err: ErrExtensionNotAllowed}
+	}
+
+	content, fi, err := openStatic(fsys, name)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	contentType := mime.TypeByExtension(path.Ext(name))
+	acceptEncoding := r.Header.Get("Accept-Encoding")
+
+	for _, enc := range acceptedPrecompressed(acceptEncoding) {
+		compressed, cfi, err := openStatic(fsys, name+enc.ext)
+		if err != nil {
+			continue
+		}
+		defer compressed.Close()
+
+		// Without a known type, ServeContent would sniff the compressed bytes.
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Encoding", enc.coding)
+		return serveStatic(w, r, compressed, name, cfi.ModTime(), cfi.Size(), enc.coding)
+	}
+
+	if r.Header.Get("Range") == "" && fi.Size() >= minGzipSize && encodingQuality(acceptEncoding, "gzip") > 0 && isCompressible(contentType) {
+		return serveGzipped(w, r, content, name, contentType, fi.ModTime(), fi.Size())
+	}
+
+	return serveStatic(w, r, content, name, fi.ModTime(), fi.Size(), "")
+}
+
+// acceptedPrecompressed returns the precompressed codings the client accepts, the most preferred first.
+func acceptedPrecompressed(acceptEncoding string) []precompressed {
+	var result []precompressed
+	weights := make(map[string]float64)
+	for _, enc := range precompressedEncodings {
+		if q := encodingQuality(acceptEncoding, enc.coding); q > 0 {
+			result = append(result, enc)
+			weights[enc.coding] = q
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return weights[result[i].coding] > weights[result[j].coding]
+	})
+	return result
+}
+
+// staticFile is an opened file of a fs.FS that supports seeking.
+type staticFile struct {
+	io.ReadSeeker
+	io.Closer
+}
+
+// openStatic opens the regular file name in fsys. Files of file systems without Seek are read into
+// memory. Errors are of type *DownloadError.
+func openStatic(fsys fs.FS, name string) (*staticFile, fs.FileInfo, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, &DownloadError{Name: name, Status: http.StatusNotFound, Err: err}
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, &DownloadError{Name: name, Status: http.StatusInternalServerError, Err: err}
+	}
+	if fi.IsDir() {
+		f.Close()
+		return nil, nil, &DownloadError{Name: name, Status: http.StatusNotFound, Err: fs.ErrNotExist}
+	}
+
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return &staticFile{ReadSeeker: rs, Closer: f}, fi, nil
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, &DownloadError{Name: name, Status: http.StatusInternalServerError, Err: err}
+	}
+	return &staticFile{ReadSeeker: bytes.NewReader(data), Closer: f}, fi, nil
+}
+
+// staticETag returns the ETag of a representation of content in the given coding ("" for none).
+func staticETag(content io.ReadSeeker, modtime time.Time, size int64, coding string) (string, error) {
+	etag, err := contentETag(content, modtime, size)
+	if err != nil || coding == "" {
+		return etag, err
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + coding + `"`, nil
+}
+
+// serveStatic serves content with http.ServeContent, which handles Range and conditional requests.
+func serveStatic(w http.ResponseWriter, r *http.Request, content io.ReadSeeker, name string, modtime time.Time, size int64, coding string) error {
+	etag, err := staticETag(content, modtime, size, coding)
+	if err != nil {
+		return &DownloadError{Name: name, Status: http.StatusInternalServerError, Err: err}
+	}
+	w.Header().Set("ETag", etag)
+
+	http.ServeContent(w, r, name, modtime, content)
+	return nil
+}
+
+// serveGzipped compresses content on the fly. As the length of the result is unknown, Range requests are
+// not supported for this representation; conditional requests are answered by ETag and modification time.
+func serveGzipped(w http.ResponseWriter, r *http.Request, content io.ReadSeeker, name, contentType string, modtime time.Time, size int64) error {
+	etag, err := staticETag(content, modtime, size, "gzip")
+	if err != nil {
+		return &DownloadError{Name: name, Status: http.StatusInternalServerError, Err: err}
+	}
+	w.Header().Set("ETag", etag)
+	if !modtime.IsZero() {
+		w.Header().Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, modtime) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return nil
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := io.Copy(gz, content); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// notModified evaluates If-None-Match and If-Modified-Since for a GET or HEAD request.
+func notModified(r *http.Request, etag string, modtime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag, true)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modtime.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modtime.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// etagMatches reports whether etag is in the list of an If-Match or If-None-Match header. The weak
+// comparison ignores W/ prefixes, the strong comparison never matches weak ETags.
+func etagMatches(header, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	if weak {
+		etag = strings.TrimPrefix(etag, "W/")
+	} else if strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
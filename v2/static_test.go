@@ -0,0 +1,137 @@
+package toolkit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestServeStaticFile(t *testing.T) {
+	modtime := time.Date(2023, 3, 12, 10, 0, 0, 0, time.UTC)
+	js := strings.Repeat("console.log('hello');\n", 100)
+	fsys := fstest.MapFS{
+		"app.js":    {Data: []byte(js), ModTime: modtime},
+		"app.js.br": {Data: []byte("brotli bytes"), ModTime: modtime},
+		"app.js.gz": {Data: []byte("gzip bytes"), ModTime: modtime},
+		"style.css": {Data: []byte(strings.Repeat("body { margin: 0; }\n", 100)), ModTime: modtime},
+		"small.css": {Data: []byte("body {}"), ModTime: modtime},
+		"image.png": {Data: bytes.Repeat([]byte{0x89}, 2048), ModTime: modtime},
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		headers  map[string]string
+		status   int
+		encoding string
+		body     string
+	}{
+		{name: "brotli preferred", file: "app.js", headers: map[string]string{"Accept-Encoding": "gzip, br"}, status: http.StatusOK, encoding: "br", body: "brotli bytes"},
+		{name: "gzip by weight", file: "app.js", headers: map[string]string{"Accept-Encoding": "br;q=0.5, gzip"}, status: http.StatusOK, encoding: "gzip", body: "gzip bytes"},
+		{name: "brotli refused", file: "app.js", headers: map[string]string{"Accept-Encoding": "br;q=0, gzip"}, status: http.StatusOK, encoding: "gzip", body: "gzip bytes"},
+		{name: "identity", file: "app.js", status: http.StatusOK, body: js},
+		{name: "precompressed range", file: "app.js", headers: map[string]string{"Accept-Encoding": "br", "Range": "bytes=0-5"}, status: http.StatusPartialContent, encoding: "br", body: "brotli"},
+		{name: "on the fly", file: "style.css", headers: map[string]string{"Accept-Encoding": "gzip"}, status: http.StatusOK, encoding: "gzip"},
+		{name: "range uncompressed", file: "style.css", headers: map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-3"}, status: http.StatusPartialContent, body: "body"},
+		{name: "too small", file: "small.css", headers: map[string]string{"Accept-Encoding": "gzip"}, status: http.StatusOK, body: "body {}"},
+		{name: "not compressible", file: "image.png", headers: map[string]string{"Accept-Encoding": "gzip"}, status: http.StatusOK},
+		{name: "missing", file: "missing.js", status: http.StatusNotFound},
+		{name: "traversal", file: "../app.js", status: http.StatusForbidden},
+	}
+
+	var tools Tools
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+
+		err := tools.ServeStaticFile(rr, r, fsys, test.file)
+
+		if status := downloadStatus(t, rr, err); status != test.status {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, test.status, status)
+			continue
+		}
+		if got := rr.Header().Get("Content-Encoding"); got != test.encoding {
+			t.Errorf("%s: expected Content-Encoding %q, got %q\n", test.name, test.encoding, got)
+		}
+		if test.status >= 400 {
+			continue
+		}
+		if got := rr.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("%s: expected Vary: Accept-Encoding, got %q\n", test.name, got)
+		}
+		if test.body != "" && rr.Body.String() != test.body {
+			t.Errorf("%s: unexpected body %q\n", test.name, rr.Body.String())
+		}
+		if test.file == "app.js" && !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/javascript") {
+			t.Errorf("%s: unexpected Content-Type %s\n", test.name, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestServeStaticFileGzipOnTheFly(t *testing.T) {
+	css := strings.Repeat("body { margin: 0; }\n", 100)
+	fsys := fstest.MapFS{"style.css": {Data: []byte(css), ModTime: time.Date(2023, 3, 12, 10, 0, 0, 0, time.UTC)}}
+
+	var tools Tools
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	if err := tools.ServeStaticFile(rr, r, fsys, "style.css"); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Header().Get("Content-Length") != "" {
+		t.Error("Content-Length must not be set for compressed content")
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(zr)
+	if string(data) != css {
+		t.Error("Decompressed body differs from file")
+	}
+
+	// The ETag of the compressed representation is honored by If-None-Match
+	etag := rr.Header().Get("ETag")
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	_ = tools.ServeStaticFile(rr, r, fsys, "style.css")
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d\n", http.StatusNotModified, rr.Code)
+	}
+}
+
+func TestEncodingQuality(t *testing.T) {
+	tests := []struct {
+		header string
+		coding string
+		want   float64
+	}{
+		{header: "gzip, deflate, br", coding: "br", want: 1},
+		{header: "gzip;q=0.5", coding: "gzip", want: 0.5},
+		{header: "x-gzip", coding: "gzip", want: 1},
+		{header: "*;q=0.3", coding: "br", want: 0.3},
+		{header: "*;q=0.3, br;q=0", coding: "br", want: 0},
+		{header: "", coding: "gzip", want: 0},
+		{header: "", coding: "identity", want: 1},
+		{header: "identity;q=0", coding: "identity", want: 0},
+	}
+
+	for _, test := range tests {
+		if got := encodingQuality(test.header, test.coding); got != test.want {
+			t.Errorf("%q/%s: expected %v, got %v\n", test.header, test.coding, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package toolkit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NDJSONContentType is the media type of newline delimited JSON.
+const NDJSONContentType = "application/x-ndjson"
+
+// defaultStreamFlushInterval is used if StreamFlushInterval is not set.
+const defaultStreamFlushInterval = time.Second
+
+// JSONStream writes a response value by value instead of marshalling it as a whole. It is created by
+// NewNDJSONStream or NewJSONArrayStream. Buffered values are flushed to the client periodically, see
+// Tools.StreamFlushInterval, and compressed if Tools.Compression is set. Values are encoded according
+// to Tools.JSON, except that NDJSON ignores Indent and Prefix, as each value must be a single line. A
+// JSONStream is safe for concurrent use.
+type JSONStream struct {
+	w     http.ResponseWriter
+	ctx   context.Context
+	array bool
+	cw    *compressResponseWriter
+
+	opts   JSONOptions
+	indent string
+
+	mu     sync.Mutex
+	count  int
+	dirty  bool
+	closed bool
+	stop   chan struct{}
+}
+
+// NewNDJSONStream writes the status and headers and returns a stream that writes each value as a line
+// of newline delimited JSON (application/x-ndjson).
+func (t *Tools) NewNDJSONStream(w http.ResponseWriter, r *http.Request, status int, headers ...http.Header) *JSONStream {
+	return t.newJSONStream(w, r, status, false, headers)
+}
+
+// NewJSONArrayStream writes the status and headers and returns a stream that writes the values as the
+// elements of a JSON array. Close ends the array.
+func (t *Tools) NewJSONArrayStream(w http.ResponseWriter, r *http.Request, status int, headers ...http.Header) *JSONStream {
+	return t.newJSONStream(w, r, status, true, headers)
+}
+
+func (t *Tools) newJSONStream(w http.ResponseWriter, r *http.Request, status int, array bool, headers []http.Header) *JSONStream {
+	if len(headers) > 0 {
+		for k, v := range headers[0] {
+			w.Header()[k] = v
+		}
+	}
+	if array {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", NDJSONContentType)
+	}
+	// Streams are of unknown length.
+	w.Header().Del("Content-Length")
+
+	s := &JSONStream{ctx: r.Context(), array: array, opts: t.JSON, stop: make(chan struct{})}
+	if t.Compression != nil {
+		s.cw = t.Compression.newWriter(w, r)
+		w = s.cw
+	}
+	s.w = w
+	w.WriteHeader(status)
+	if array {
+		s.indent = t.jsonIndent(r)
+		_, _ = io.WriteString(w, t.JSON.Prefix+"[")
+		s.dirty = true
+	}
+
+	interval := t.StreamFlushInterval
+	if interval <= 0 {
+		interval = defaultStreamFlushInterval
+	}
+	go s.flushPeriodically(interval)
+
+	return s
+}
+
+// flushPeriodically flushes written values until the stream is closed.
+func (s *JSONStream) flushPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if !s.closed {
+				s.flush()
+			}
+			s.mu.Unlock()
+		case <-s.stop:
+			return
+		case <-s.ctx.Done():
+			// The handler may have returned, so the writer must not be used anymore.
+			return
+		}
+	}
+}
+
+// flush sends buffered data to the client. The caller must hold s.mu.
+func (s *JSONStream) flush() {
+	if !s.dirty {
+		return
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	s.dirty = false
+}
+
+// Encode writes v. It returns the error of the request context if the client has gone away.
+func (s *JSONStream) Encode(v interface{}) error {
+	// Elements of an indented array are indented one level.
+	body, err := s.opts.encode(v, "", s.indent, s.indent)
+	if err != nil {
+		return err
+	}
+	defer body.release()
+	if !s.array {
+		body.buf.WriteByte('\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errors.New("stream is closed")
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	if s.array {
+		separator := ""
+		if s.count > 0 {
+			separator = ","
+		}
+		if s.indent != "" {
+			separator += "\n" + s.indent
+		}
+		if _, err := io.WriteString(s.w, separator); err != nil {
+			return err
+		}
+	}
+	if _, err := s.w.Write(body.Bytes()); err != nil {
+		return err
+	}
+	s.count++
+	s.dirty = true
+	return nil
+}
+
+// Flush sends the values written so far to the client.
+func (s *JSONStream) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flush()
+}
+
+// Count returns the number of values written.
+func (s *JSONStream) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// Close ends a JSON array and flushes the stream. It must be called when all values have been written.
+func (s *JSONStream) Close() error {
+	return s.close(true)
+}
+
+// close stops the stream. A JSON array is only ended if complete is set, so that clients can tell an
+// aborted stream from a complete one.
+func (s *JSONStream) close(complete bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+
+	var err error
+	if complete && s.array {
+		end := "]"
+		if s.indent != "" && s.count > 0 {
+			end = "\n]"
+		}
+		_, err = io.WriteString(s.w, end)
+		s.dirty = true
+	}
+	if s.cw != nil {
+		if closeErr := s.cw.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	s.flush()
+	return err
+}
+
+// StreamNDJSON writes the values produced by values as newline delimited JSON. values calls yield for
+// each value and must return yield's error if it is non-nil. Use ChannelValues to stream the values of
+// a channel. The error of values or of writing is returned; the status has been sent by then.
+func StreamNDJSON[T any](t *Tools, w http.ResponseWriter, r *http.Request, status int, values func(yield func(T) error) error, headers ...http.Header) error {
+	return stream(t.NewNDJSONStream(w, r, status, headers...), values)
+}
+
+// StreamJSONArray writes the values produced by values as JSON array, like StreamNDJSON. If values
+// fails, the array is not ended, so that clients don't mistake the partial result for a complete one.
+func StreamJSONArray[T any](t *Tools, w http.ResponseWriter, r *http.Request, status int, values func(yield func(T) error) error, headers ...http.Header) error {
+	return stream(t.NewJSONArrayStream(w, r, status, headers...), values)
+}
+
+func stream[T any](s *JSONStream, values func(yield func(T) error) error) error {
+	err := values(func(v T) error {
+		return s.Encode(v)
+	})
+	if err != nil {
+		_ = s.close(false)
+		return err
+	}
+	return s.Close()
+}
+
+// ChannelValues returns a function to pass to StreamNDJSON or StreamJSONArray, which yields the values
+// received from ch until ch is closed or ctx, usually the context of the request, is done.
+func ChannelValues[T any](ctx context.Context, ch <-chan T) func(yield func(T) error) error {
+	return func(yield func(T) error) error {
+		for {
+			select {
+			case v, ok := <-ch:
+				if !ok {
+					return nil
+				}
+				if err := yield(v); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// NDJSONLineError is returned by ReadNDJSON for a line that can't be read. Err is one of the errors of
+// ReadJSON, the ValidationErrors of the value or the error returned by the callback.
+type NDJSONLineError struct {
+	Line int
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *NDJSONLineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the error of the line.
+func (e *NDJSONLineError) Unwrap() error { return e.Err }
+
+// StatusCode returns the status of the line's error, by default http.StatusBadRequest.
+func (e *NDJSONLineError) StatusCode() int {
+	var statusErr StatusCoder
+	if errors.As(e.Err, &statusErr) {
+		return statusErr.StatusCode()
+	}
+	return http.StatusBadRequest
+}
+
+// ReadNDJSON reads a body of newline delimited JSON and calls fn for the value of each line, in order.
+// Empty lines are skipped. MaxJSONSize limits the length of a line and MaxNDJSONSize the whole body,
+// after decompressing it if it has a Content-Encoding. AllowUnknownFields and ValidateJSON apply to
+// each value. ReadNDJSON stops at the first line that can't be decoded or for which fn returns an error
+// and returns an *NDJSONLineError.
+func ReadNDJSON[T any](t *Tools, w http.ResponseWriter, r *http.Request, fn func(T) error) error {
+	maxBytes := t.maxJSONSize()
+	if err := t.limitBody(w, r, t.maxNDJSONSize()); err != nil {
+		return err
+	}
+
+	initial := 4096
+	if maxBytes < initial {
+		initial = maxBytes
+	}
+	body := &errorRecorder{r: r.Body}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, initial), maxBytes)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		// Complete lines are read before the error of reading the body; the rest of the body, which
+		// was cut off by the error, is not a line.
+		if atEOF && body.err != nil && body.err != io.EOF {
+			if bytes.IndexByte(data, '\n') >= 0 {
+				return bufio.ScanLines(data, false)
+			}
+			return 0, nil, body.err
+		}
+		return bufio.ScanLines(data, atEOF)
+	})
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var v T
+		if err := t.decodeJSONValue(data, &v); err != nil {
+			return &NDJSONLineError{Line: line, Err: err}
+		}
+		if err := fn(v); err != nil {
+			return &NDJSONLineError{Line: line, Err: err}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.Is(err, bufio.ErrTooLong):
+			err = &JSONTooLargeError{Limit: int64(maxBytes)}
+		case errors.As(err, &maxBytesError):
+			err = &JSONTooLargeError{Limit: maxBytesError.Limit}
+		}
+		return &NDJSONLineError{Line: line + 1, Err: err}
+	}
+	return nil
+}
+
+// errorRecorder records the error of reading r.
+type errorRecorder struct {
+	r   io.Reader
+	err error
+}
+
+// Read implements io.Reader.
+func (e *errorRecorder) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err != nil {
+		e.err = err
+	}
+	return n, err
+}
+
+// maxNDJSONSize returns MaxNDJSONSize or the default of 64MB.
+func (t *Tools) maxNDJSONSize() int {
+	if t.MaxNDJSONSize != 0 {
+		return t.MaxNDJSONSize
+	}
+	return 64 * 1024 * 1024
+}
+
+// decodeJSONValue decodes a single JSON value like ReadJSON decodes a body.
+func (t *Tools) decodeJSONValue(data []byte, v interface{}) error {
+	input := &jsonInput{r: bytes.NewReader(data), maxBytes: len(data)}
+	dec := json.NewDecoder(input)
+	if !t.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(v); err != nil {
+		return input.error(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return &JSONMultipleValuesError{}
+	}
+	if t.ValidateJSON {
+		return t.Validate(v)
+	}
+	return nil
+}
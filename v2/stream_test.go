@@ -0,0 +1,255 @@
+package toolkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamNDJSON(t *testing.T) {
+	tool := Tools{}
+	values := func(yield func(int) error) error {
+		for i := 1; i <= 3; i++ {
+			if err := yield(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := StreamNDJSON(&tool, rr, req, http.StatusOK, values); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := rr.Body.String(); got != "1\n2\n3\n" {
+		t.Errorf("expected three lines, got %q\n", got)
+	}
+	if got := rr.Header().Get("Content-Type"); got != NDJSONContentType {
+		t.Errorf("expected content type %s, got %s\n", NDJSONContentType, got)
+	}
+	if !rr.Flushed {
+		t.Error("expected the stream to be flushed")
+	}
+}
+
+func TestStreamJSONArray(t *testing.T) {
+	tool := Tools{}
+
+	tests := []struct {
+		name    string
+		values  []string
+		fail    bool
+		want    string
+		wantErr bool
+	}{
+		{name: "values", values: []string{"a", "b"}, want: `["a","b"]`},
+		{name: "empty", want: `[]`},
+		{name: "failing source", values: []string{"a"}, fail: true, want: `["a"`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		ch := make(chan string, len(test.values))
+		for _, v := range test.values {
+			ch <- v
+		}
+		close(ch)
+
+		values := ChannelValues(context.Background(), ch)
+		if test.fail {
+			values = func(yield func(string) error) error {
+				_ = ChannelValues(context.Background(), ch)(yield)
+				return errors.New("database gone")
+			}
+		}
+
+		rr := httptest.NewRecorder()
+		err := StreamJSONArray(&tool, rr, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, values)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error %v\n", test.name, err)
+		}
+		if got := rr.Body.String(); got != test.want {
+			t.Errorf("%s: expected %s, got %s\n", test.name, test.want, got)
+		}
+		if !test.fail {
+			var decoded []string
+			if err := json.Unmarshal(rr.Body.Bytes(), &decoded); err != nil {
+				t.Errorf("%s: invalid JSON: %v\n", test.name, err)
+			}
+		}
+	}
+}
+
+func TestJSONStreamOptions(t *testing.T) {
+	opts := JSONOptions{Indent: "  ", Prefix: XSSIPrefix, DisableHTMLEscaping: true}
+	values := func(yield func(map[string]string) error) error {
+		for _, v := range []string{"<a>", "<b>"} {
+			if err := yield(map[string]string{"v": v}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	encoders := 0
+	countingEncoder := func(w io.Writer) JSONEncoder {
+		encoders++
+		return json.NewEncoder(w)
+	}
+
+	tests := []struct {
+		name   string
+		opts   JSONOptions
+		array  bool
+		target string
+		want   string
+	}{
+		{name: "ndjson", opts: opts, want: "{\"v\":\"<a>\"}\n{\"v\":\"<b>\"}\n"},
+		{name: "array", opts: opts, array: true, want: XSSIPrefix + "[\n  {\n    \"v\": \"<a>\"\n  },\n  {\n    \"v\": \"<b>\"\n  }\n]"},
+		{name: "pretty param", opts: JSONOptions{PrettyParam: true}, array: true, target: "/?pretty", want: "[\n  {\n    \"v\": \"\\u003ca\\u003e\"\n  },\n  {\n    \"v\": \"\\u003cb\\u003e\"\n  }\n]"},
+		{name: "encoder", opts: JSONOptions{NewEncoder: countingEncoder}, array: true, want: `[{"v":"\u003ca\u003e"},{"v":"\u003cb\u003e"}]`},
+	}
+
+	for _, test := range tests {
+		tool := Tools{JSON: test.opts}
+		target := test.target
+		if target == "" {
+			target = "/"
+		}
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rr := httptest.NewRecorder()
+
+		var err error
+		if test.array {
+			err = StreamJSONArray(&tool, rr, req, http.StatusOK, values)
+		} else {
+			err = StreamNDJSON(&tool, rr, req, http.StatusOK, values)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rr.Body.String(); got != test.want {
+			t.Errorf("%s: expected %q, got %q\n", test.name, test.want, got)
+		}
+		if test.array {
+			var decoded []map[string]string
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(rr.Body.String(), XSSIPrefix)), &decoded); err != nil {
+				t.Errorf("%s: invalid JSON: %v\n", test.name, err)
+			}
+		}
+	}
+
+	if encoders != 2 {
+		t.Errorf("expected NewEncoder to be used for both values, got %d calls\n", encoders)
+	}
+}
+
+func TestStreamCancellation(t *testing.T) {
+	tool := Tools{}
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+	ch := make(chan int)
+	done := make(chan error)
+	go func() {
+		done <- StreamNDJSON(&tool, httptest.NewRecorder(), req, http.StatusOK, ChannelValues(ctx, ch))
+	}()
+
+	ch <- 1
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v\n", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream didn't stop after the client went away")
+	}
+}
+
+func TestJSONStreamPeriodicFlush(t *testing.T) {
+	tool := Tools{StreamFlushInterval: 10 * time.Millisecond}
+	rr := httptest.NewRecorder()
+	s := tool.NewNDJSONStream(rr, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK)
+	defer s.Close()
+
+	if err := s.Encode(map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		flushed := rr.Flushed
+		s.mu.Unlock()
+		if flushed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the stream to be flushed periodically")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReadNDJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		tool      Tools
+		want      int
+		errorLine int
+		status    int
+	}{
+		{name: "valid", body: "{\"foo\": \"a\"}\n\n{\"foo\": \"b\"}\r\n{\"foo\": \"c\"}", want: 3},
+		{name: "syntax error", body: "{\"foo\": \"a\"}\n{\"foo\": }\n", want: 1, errorLine: 2, status: http.StatusBadRequest},
+		{name: "unknown field", body: "{\"bar\": 1}\n", errorLine: 1, status: http.StatusBadRequest},
+		{name: "two values", body: "{\"foo\": \"a\"} {\"foo\": \"b\"}\n", errorLine: 1, status: http.StatusBadRequest},
+		{name: "line too long", body: "{\"foo\": \"a\"}\n{\"foo\": \"" + strings.Repeat("x", 100) + "\"}\n", tool: Tools{MaxJSONSize: 50}, want: 1, errorLine: 2, status: http.StatusRequestEntityTooLarge},
+		{name: "body too large", body: strings.Repeat("{\"foo\": \"a\"}\n", 3), tool: Tools{MaxNDJSONSize: 30}, want: 2, errorLine: 3, status: http.StatusRequestEntityTooLarge},
+		{name: "callback error", body: "{\"foo\": \"a\"}\n{\"foo\": \"stop\"}\n", want: 1, errorLine: 2, status: http.StatusConflict},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+		var got []string
+		err := ReadNDJSON(&test.tool, httptest.NewRecorder(), req, func(v struct {
+			Foo string `json:"foo"`
+		}) error {
+			if v.Foo == "stop" {
+				return NewProblem(http.StatusConflict, "stop")
+			}
+			got = append(got, v.Foo)
+			return nil
+		})
+
+		if len(got) != test.want {
+			t.Errorf("%s: expected %d values, got %v\n", test.name, test.want, got)
+		}
+		if test.errorLine == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v\n", test.name, err)
+			}
+			continue
+		}
+
+		var lineErr *NDJSONLineError
+		if !errors.As(err, &lineErr) {
+			t.Errorf("%s: expected *NDJSONLineError, got %v\n", test.name, err)
+			continue
+		}
+		if lineErr.Line != test.errorLine {
+			t.Errorf("%s: expected error in line %d, got %d\n", test.name, test.errorLine, lineErr.Line)
+		}
+		if lineErr.StatusCode() != test.status {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, test.status, lineErr.StatusCode())
+		}
+	}
+}
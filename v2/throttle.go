@@ -0,0 +1,209 @@
+package toolkit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DownloadLimits configures a DownloadLimiter. Zero values mean no limit.
+type DownloadLimits struct {
+	// PerConnection is the maximum rate of a single download in bytes per second.
+	PerConnection int64
+	// Global is the maximum rate of all downloads together in bytes per second.
+	Global int64
+	// MaxConcurrent is the maximum number of downloads served at the same time. Further requests are
+	// answered with 429 Too Many Requests.
+	MaxConcurrent int
+	// RetryAfter is sent in the Retry-After header of 429 responses. The default is 10 seconds.
+	RetryAfter time.Duration
+}
+
+// DownloadLimiter throttles downloads and limits the number of concurrent downloads. Assign it to
+// Tools.DownloadLimiter to apply it to all download functions of the toolkit, or use Middleware to apply
+// it to any handler. A DownloadLimiter is safe for concurrent use and is meant to be shared.
+type DownloadLimiter struct {
+	limits DownloadLimits
+	global *tokenBucket
+
+	mu     sync.Mutex
+	active int
+}
+
+// NewDownloadLimiter creates a DownloadLimiter with the given limits.
+func NewDownloadLimiter(limits DownloadLimits) *DownloadLimiter {
+	if limits.RetryAfter <= 0 {
+		limits.RetryAfter = 10 * time.Second
+	}
+	l := &DownloadLimiter{limits: limits}
+	if limits.Global > 0 {
+		l.global = newTokenBucket(limits.Global)
+	}
+	return l
+}
+
+// Middleware applies the limits to every request handled by next.
+func (l *DownloadLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lw, release, ok := l.acquire(w, r)
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		defer release()
+		next.ServeHTTP(lw, r)
+	})
+}
+
+// Active returns the number of downloads currently served.
+func (l *DownloadLimiter) Active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active
+}
+
+// acquire reserves a download slot and returns a throttled writer and a function to release the slot.
+// If no slot is available, ok is false and only the Retry-After header is set; the caller answers with
+// 429 Too Many Requests.
+func (l *DownloadLimiter) acquire(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func(), bool) {
+	l.mu.Lock()
+	if l.limits.MaxConcurrent > 0 && l.active >= l.limits.MaxConcurrent {
+		l.mu.Unlock()
+		seconds := int(math.Ceil(l.limits.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		return nil, nil, false
+	}
+	l.active++
+	l.mu.Unlock()
+
+	release := func() {
+		l.mu.Lock()
+		l.active--
+		l.mu.Unlock()
+	}
+
+	if l.limits.PerConnection <= 0 && l.global == nil {
+		return w, release, true
+	}
+
+	throttled := &throttledWriter{ResponseWriter: w, ctx: r.Context(), global: l.global}
+	if l.limits.PerConnection > 0 {
+		throttled.own = newTokenBucket(l.limits.PerConnection)
+	}
+	return throttled, release, true
+}
+
+// limitDownload applies the Tools' DownloadLimiter, if any. See DownloadLimiter.acquire.
+func (t *Tools) limitDownload(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func(), bool) {
+	if t.DownloadLimiter == nil {
+		return w, func() {}, true
+	}
+	return t.DownloadLimiter.acquire(w, r)
+}
+
+// throttledWriter is a http.ResponseWriter which writes at the rate of its own and the global token bucket.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx    context.Context
+	own    *tokenBucket
+	global *tokenBucket
+}
+
+// Write writes p in chunks, waiting for the token buckets before each chunk. It stops early when the
+// request's context is done, e.g. because the client went away.
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		for _, b := range []*tokenBucket{tw.own, tw.global} {
+			if b != nil && n > b.chunk {
+				n = b.chunk
+			}
+		}
+
+		var wait time.Duration
+		for _, b := range []*tokenBucket{tw.own, tw.global} {
+			if b != nil {
+				if d := b.take(n); d > wait {
+					wait = d
+				}
+			}
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-tw.ctx.Done():
+				timer.Stop()
+				return written, tw.ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		m, err := tw.ResponseWriter.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Flush implements http.Flusher if the underlying writer does.
+func (tw *throttledWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// tokenBucket hands out a rate of bytes per second. Tokens are reserved in advance: take returns how long
+// the caller has to wait before it may use the tokens, which keeps waiting callers in order.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	chunk  int
+}
+
+// newTokenBucket creates a full token bucket with a rate in bytes per second. Writes are split into chunks
+// of a tenth of a second, so that concurrent downloads are interleaved smoothly.
+func newTokenBucket(rate int64) *tokenBucket {
+	chunk := int(rate / 10)
+	if chunk < 1 {
+		chunk = 1
+	}
+	if chunk > 32*1024 {
+		chunk = 32 * 1024
+	}
+	return &tokenBucket{
+		rate:   float64(rate),
+		burst:  float64(chunk),
+		tokens: float64(chunk),
+		last:   time.Now(),
+		chunk:  chunk,
+	}
+}
+
+// take reserves n tokens and returns the time to wait until they are available.
+func (b *tokenBucket) take(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
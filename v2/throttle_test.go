@@ -0,0 +1,111 @@
+package toolkit
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadLimiterPerConnection(t *testing.T) {
+	tools := Tools{DownloadLimiter: NewDownloadLimiter(DownloadLimits{PerConnection: 100 * 1024})}
+	content := bytes.Repeat([]byte("x"), 50*1024)
+
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	err := tools.DownloadContent(rr, httptest.NewRequest("GET", "/", nil), bytes.NewReader(content), "x.bin", time.Time{}, -1)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Body.Len() != len(content) {
+		t.Errorf("Expected %d bytes, got %d\n", len(content), rr.Body.Len())
+	}
+	// 50 KB at 100 KB/s take about half a second; the first chunk is sent without delay.
+	if elapsed < 350*time.Millisecond {
+		t.Errorf("Download not throttled, took %v\n", elapsed)
+	}
+	if tools.DownloadLimiter.Active() != 0 {
+		t.Errorf("Download slot not released")
+	}
+}
+
+func TestDownloadLimiterGlobal(t *testing.T) {
+	limiter := NewDownloadLimiter(DownloadLimits{Global: 100 * 1024})
+	content := bytes.Repeat([]byte("x"), 25*1024)
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+
+	// Two downloads of 25 KB share 100 KB/s and need about half a second together.
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 350*time.Millisecond {
+		t.Errorf("Global bandwidth not shared, took %v\n", elapsed)
+	}
+}
+
+func TestDownloadLimiterMaxConcurrent(t *testing.T) {
+	limiter := NewDownloadLimiter(DownloadLimits{MaxConcurrent: 1, RetryAfter: 1500 * time.Millisecond})
+
+	started, finish := make(chan struct{}), make(chan struct{})
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+	}))
+
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	<-started
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d\n", http.StatusTooManyRequests, rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Expected Retry-After 2, got %s\n", got)
+	}
+
+	close(finish)
+}
+
+func TestDownloadLimiterNoValidators(t *testing.T) {
+	limiter := NewDownloadLimiter(DownloadLimits{MaxConcurrent: 1})
+	tool := Tools{DownloadLimiter: limiter}
+
+	started, finish := make(chan struct{}), make(chan struct{})
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+	}))
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	<-started
+
+	rr := httptest.NewRecorder()
+	modtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	err := tool.DownloadContent(rr, httptest.NewRequest("GET", "/", nil), bytes.NewReader([]byte("content")), "a.txt", modtime, 7)
+	if status := downloadStatus(t, rr, err); status != http.StatusTooManyRequests || !errors.Is(err, ErrTooManyDownloads) {
+		t.Errorf("Expected status %d, got %d and %v\n", http.StatusTooManyRequests, status, err)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "10" {
+		t.Errorf("Expected Retry-After 10, got %q\n", got)
+	}
+	if etag, lastModified := rr.Header().Get("ETag"), rr.Header().Get("Last-Modified"); etag != "" || lastModified != "" {
+		t.Errorf("Expected no validators on 429, got ETag %q and Last-Modified %q\n", etag, lastModified)
+	}
+
+	close(finish)
+}
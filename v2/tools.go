@@ -11,7 +11,6 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
 )
@@ -33,6 +32,63 @@ type Tools struct {
 	AllowedFileTypes   []string
 	MaxJSONSize        int
 	AllowUnknownFields bool
+	// ValidateJSON makes ReadJSON validate the decoded data according to its validate tags. See Validate.
+	ValidateJSON bool
+	// ProblemDetails makes ErrorJSON write RFC 9457 problem details (application/problem+json)
+	// instead of a JSONResponse.
+	ProblemDetails bool
+	// Codecs are the codecs WriteResponse and ReadBody choose from. Nil means JSON and XML; register
+	// YAMLCodec, MessagePackCodec or CBORCodec here to offer them.
+	Codecs *CodecRegistry
+	// StreamFlushInterval is how often streams created by NewNDJSONStream and NewJSONArrayStream send
+	// buffered values to the client. The default is one second.
+	StreamFlushInterval time.Duration
+	// MaxNDJSONSize limits the body read by ReadNDJSON, after decompression. The default is 64MB.
+	MaxNDJSONSize int
+	// Compression compresses the responses written for a request, if set: those of WriteResponse,
+	// WriteJSONFor, ErrorJSONFor, WriteJSONCached, WritePage, ProblemHandler and of streams. Responses of
+	// WriteJSON and ErrorJSON can't be negotiated without the request; use Compression.Middleware for
+	// them.
+	Compression *Compression
+	// Decompressors adds content codings request bodies may be encoded with, e.g. "zstd". gzip,
+	// deflate and br (brotli) are always supported.
+	Decompressors map[string]Decompressor
+	// Schemas holds the JSON Schemas ReadJSON validates bodies against before decoding them, by the
+	// type of the data.
+	Schemas *SchemaRegistry
+	// ValidateResponses makes WriteJSON validate its output against the schema registered for the type
+	// of the data and fail with a *ResponseSchemaError instead of writing an invalid response. It is
+	// meant for development.
+	ValidateResponses bool
+	// JSON configures the encoding of JSON responses, e.g. pretty printing. See WithJSONOptions for
+	// options of a single call.
+	JSON JSONOptions
+	// DefaultPageSize is the page size ReadPage returns if the request has no size parameter. The
+	// default is 20.
+	DefaultPageSize int
+	// MaxPageSize caps the page size clients can request. The default is 100.
+	MaxPageSize int
+	// CursorSigner signs and verifies the pagination cursors of EncodeCursor and ReadPage.
+	CursorSigner *URLSigner
+
+	// PreserveUnicodeSlugs makes Slugify keep Unicode letters and digits instead of transliterating them to ASCII.
+	PreserveUnicodeSlugs bool
+	// Language is the default language tag (e.g. "de" or "tr-TR") for transliteration and slugs.
+	Language string
+
+	// DownloadRoot confines DownloadStaticFile to files below this directory (see DownloadStaticFileFromRoot).
+	DownloadRoot string
+	// AllowedDownloadExtensions restricts root-confined downloads to files with one of these extensions,
+	// e.g. ".pdf". An empty list allows all extensions.
+	AllowedDownloadExtensions []string
+	// Disposition is either DispositionAttachment (the default) to make the browser save downloads or
+	// DispositionInline to let it display them.
+	Disposition string
+	// MaxZipSize limits the total uncompressed size of the files in an archive created by DownloadZip.
+	// Zero means no limit.
+	MaxZipSize int64
+	// DownloadLimiter throttles all downloads and limits their number, if set. See NewDownloadLimiter.
+	DownloadLimiter *DownloadLimiter
 }
 
 // UploadedFile contains meta data about a file that was uploaded before.
@@ -193,135 +249,153 @@ func (t *Tools) CreateDirIfNotExist(dir string) error {
 	return nil
 }
 
-// Slugify is a simple function that creates a slug from a string or that returns an error
-// if the slug cannot be created due to an invalid input. Characters not in [a-z] or [0-9]
-// are replaced by a dash. The slug neither begins nor ends with a dash.
-func (t *Tools) Slugify(s string) (string, error) {
-	if s == "" {
-		return "", errors.New("empty string not permitted")
-	}
-	re := regexp.MustCompile(`[^a-z\d]+`)
-	result := re.ReplaceAllString(strings.ToLower(s), "-")
-	result = strings.Trim(result, "-")
-	if len(result) == 0 {
-		return "", errors.New("after trimming, slug is of zero length")
-	}
-	return result, nil
-}
-
 // DownloadStaticFile triggers the Save As Dialog in the browser to download a file to the local
-// disk rather than rendering the file in the browser.
+// disk rather than rendering the file in the browser, unless Disposition is DispositionInline.
+// The header is built by ContentDisposition, so displayName may contain any Unicode characters.
 // See https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Content-Disposition for further details
 // about the Content-Disposition header.
+// If DownloadRoot is set, pathName is interpreted relative to it and requests for files outside of the
+// root are rejected; see DownloadStaticFileFromRoot. As there is no error to return, failures are
+// answered with their status as plain text.
 func (t *Tools) DownloadStaticFile(w http.ResponseWriter, r *http.Request, pathName, displayName string) {
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", displayName))
+	if t.DownloadRoot != "" {
+		if err := t.DownloadStaticFileFromRoot(w, r, pathName, displayName); err != nil {
+			writeDownloadError(w, err)
+		}
+		return
+	}
+
+	w, release, ok := t.limitDownload(w, r)
+	if !ok {
+		writeDownloadError(w, &DownloadError{Name: displayName, Status: http.StatusTooManyRequests, Err: ErrTooManyDownloads})
+		return
+	}
+	defer release()
+
+	w.Header().Set("Content-Disposition", ContentDisposition(t.disposition(), displayName))
 	http.ServeFile(w, r, pathName)
 }
 
 // JSONResponse represents the type used for sending JSON data in Go.
 type JSONResponse struct {
 	Error   bool        `json:"error"`
-	Message string      `string:"message"`
+	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
 
-// ReadJSON reads the body of a request and converts it from JSON int data.
+// ReadJSON reads the body of a request and converts it from JSON int data. If ValidateJSON is set, the
+// data is validated afterwards and all violations are returned as ValidationErrors. Bodies compressed
+// with gzip, deflate or one of the Decompressors are decompressed; MaxJSONSize applies to both the
+// compressed and the decompressed body. If a schema is registered for the type of data in Schemas, the
+// body is validated against it first and violations are returned as SchemaErrors.
+// Errors caused by the body are of the types declared in json_errors.go, e.g. *JSONSyntaxError, so that
+// callers can tell them apart with errors.As.
 func (t *Tools) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
-	maxBytes := 1024 * 1024
-	if t.MaxJSONSize != 0 {
-		maxBytes = t.MaxJSONSize
+	dec, input, err := t.jsonDecoder(w, r)
+	if err != nil {
+		return err
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
-
-	dec := json.NewDecoder(r.Body)
-
-	if !t.AllowUnknownFields {
-		dec.DisallowUnknownFields()
+	if schema, ok := t.Schemas.Lookup(data); ok {
+		if dec, input, err = t.validateSchema(dec, input, schema); err != nil {
+			return err
+		}
 	}
 
-	err := dec.Decode(data)
+	err = dec.Decode(data)
 	if err != nil {
-		var syntaxError *json.SyntaxError
-		var unmarshalTypeError *json.UnmarshalTypeError
-		var invalidUnmarshalError *json.InvalidUnmarshalError
-
-		switch {
-		case errors.As(err, &syntaxError):
-			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
-
-		case errors.Is(err, io.ErrUnexpectedEOF):
-			return fmt.Errorf("body contains badly-formed JSON")
-
-		case errors.As(err, &unmarshalTypeError):
-			if unmarshalTypeError.Field != "" {
-				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
-			}
-			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
-
-		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
-
-		case strings.HasPrefix(err.Error(), "json: unknown field"):
-			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field")
-			return fmt.Errorf("body contains unknown key %s", fieldName)
-
-		case err.Error() == "http: request body too large":
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
-
-		case errors.As(err, &invalidUnmarshalError):
-			return fmt.Errorf("error unmarshalling JSON: %s", err.Error())
-
-		default:
-			return err
-		}
+		return input.error(err)
 	}
 
 	err = dec.Decode(&struct{}{})
 	if err != io.EOF {
-		return errors.New("only one JSON value allowed")
+		return &JSONMultipleValuesError{}
+	}
+
+	if t.ValidateJSON {
+		return t.Validate(data)
 	}
 
 	return nil
 }
 
-// WriteJSON takes arbitrary data and writes JSON with headers.
-func (t *Tools) WriteJSON(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
-	out, err := json.Marshal(data)
-	if err != nil {
-		return err
+// jsonDecoder limits the request body to MaxJSONSize, decompresses it and returns a decoder for it,
+// configured according to AllowUnknownFields, together with the input it reads from.
+func (t *Tools) jsonDecoder(w http.ResponseWriter, r *http.Request) (*json.Decoder, *jsonInput, error) {
+	maxBytes := t.maxJSONSize()
+	if err := t.limitBody(w, r, maxBytes); err != nil {
+		return nil, nil, err
 	}
-	if len(headers) > 0 {
-		for k, v := range headers[0] {
-			w.Header()[k] = v
-		}
+
+	input := &jsonInput{r: r.Body, maxBytes: maxBytes}
+	dec := json.NewDecoder(input)
+
+	if !t.AllowUnknownFields {
+		dec.DisallowUnknownFields()
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	_, err = w.Write(out)
-	if err != nil {
-		return err
+	return dec, input, nil
+}
+
+// maxJSONSize returns MaxJSONSize or the default of 1MB.
+func (t *Tools) maxJSONSize() int {
+	if t.MaxJSONSize != 0 {
+		return t.MaxJSONSize
 	}
+	return 1024 * 1024
+}
 
-	return nil
+// WriteJSON takes arbitrary data and writes JSON with headers. The encoding is configured by JSON.
+func (t *Tools) WriteJSON(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
+	return t.writeJSON(w, nil, status, data, headers...)
+}
+
+// WriteJSONFor works like WriteJSON for a response to r, so that JSON.PrettyParam applies.
+func (t *Tools) WriteJSONFor(w http.ResponseWriter, r *http.Request, status int, data interface{}, headers ...http.Header) error {
+	return t.writeJSON(w, r, status, data, headers...)
 }
 
 // ErrorJSON is a helper function that writes an error response in JSON format and optionally sets the status
-// code to the status provided by the caller. If no status was given, http.StatusBadRequest (400) is used.
+// code to the status provided by the caller. If no status was given, the status of errors implementing
+// StatusCoder is used, e.g. 413 for a *JSONTooLargeError, otherwise http.StatusBadRequest (400).
+// If ProblemDetails is set, the error is written as problem details document; see ProblemFromError. A
+// *Problem in err's chain is written as it is, with its own status.
 func (t *Tools) ErrorJSON(w http.ResponseWriter, err error, status ...int) error {
+	return t.errorJSON(w, nil, err, status)
+}
+
+// ErrorJSONFor works like ErrorJSON for a response to r, so that JSON.PrettyParam applies.
+func (t *Tools) ErrorJSONFor(w http.ResponseWriter, r *http.Request, err error, status ...int) error {
+	return t.errorJSON(w, r, err, status)
+}
+
+// errorJSON writes err for a response to r, which may be nil.
+func (t *Tools) errorJSON(w http.ResponseWriter, r *http.Request, err error, status []int) error {
 	statusCode := http.StatusBadRequest
 
+	var statusErr StatusCoder
+	if errors.As(err, &statusErr) {
+		statusCode = statusErr.StatusCode()
+	}
+
 	if len(status) > 0 {
 		statusCode = status[0]
 	}
 
+	if t.ProblemDetails {
+		var problem *Problem
+		if errors.As(err, &problem) {
+			return t.writeProblem(w, r, problem)
+		}
+		return t.writeProblem(w, r, problemFromError(err, statusCode))
+	}
+
 	payload := JSONResponse{
 		Error:   true,
 		Message: err.Error(),
 	}
 
-	return t.WriteJSON(w, statusCode, payload)
+	return t.writeJSON(w, r, statusCode, payload)
 }
 
 // PushJSONToRemote posts data to uri as JSON and returns the server's response, status code, and error, if any.
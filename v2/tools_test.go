@@ -313,6 +313,40 @@ func TestCreateSlug(t *testing.T) {
 		{sentence: "", slug: "", expErr: true},
 		{sentence: "!+/()%$-", slug: "", expErr: true},
 		{sentence: "!-a=?&%§\"'", slug: "a", expErr: false},
+		{sentence: "Crème Brûlée", slug: "creme-brulee", expErr: false},
+		{sentence: "Große Straße in Köln", slug: "grosse-strasse-in-koln", expErr: false},
+		{sentence: "Привет, мир!", slug: "privet-mir", expErr: false},
+		{sentence: "Καλημέρα κόσμε", slug: "kalimera-kosme", expErr: false},
+		{sentence: "你好世界", slug: "", expErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := tools.Slugify(test.sentence)
+		if err != nil && test.expErr == false {
+			t.Errorf("%s - received error %v\n", test.sentence, err)
+		}
+		if err == nil && test.expErr {
+			t.Errorf("%s - expected error but received none", test.sentence)
+		}
+		if err == nil && got != test.slug {
+			t.Errorf("%s - expected %s, got %s\n", test.sentence, test.slug, got)
+		}
+	}
+}
+
+func TestCreateSlugPreserveUnicode(t *testing.T) {
+	tools := Tools{PreserveUnicodeSlugs: true}
+
+	tests := []struct {
+		sentence string
+		slug     string
+		expErr   bool
+	}{
+		{sentence: "Hello World!", slug: "hello-world", expErr: false},
+		{sentence: "Crème Brûlée", slug: "crème-brûlée", expErr: false},
+		{sentence: "Привет, Мир!", slug: "привет-мир", expErr: false},
+		{sentence: "你好 世界", slug: "你好-世界", expErr: false},
+		{sentence: "!+/()%$-", slug: "", expErr: true},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,211 @@
+package toolkit
+
+import (
+	"strings"
+	"unicode"
+)
+
+// latinTable maps Latin letters with diacritics and ligatures to their plain ASCII spelling.
+var latinTable = map[rune]string{
+	// Latin-1 Supplement
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "A", 'Å': "A", 'Æ': "AE", 'Ç': "C",
+	'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E", 'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I",
+	'Ð': "D", 'Ñ': "N", 'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ö': "O", 'Ø': "O",
+	'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "U", 'Ý': "Y", 'Þ': "TH", 'ß': "ss",
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae", 'ç': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ð': "d", 'ñ': "n", 'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ý': "y", 'þ': "th", 'ÿ': "y",
+
+	// Latin Extended-A
+	'Ā': "A", 'ā': "a", 'Ă': "A", 'ă': "a", 'Ą': "A", 'ą': "a",
+	'Ć': "C", 'ć': "c", 'Ĉ': "C", 'ĉ': "c", 'Ċ': "C", 'ċ': "c", 'Č': "C", 'č': "c",
+	'Ď': "D", 'ď': "d", 'Đ': "D", 'đ': "d",
+	'Ē': "E", 'ē': "e", 'Ĕ': "E", 'ĕ': "e", 'Ė': "E", 'ė': "e", 'Ę': "E", 'ę': "e", 'Ě': "E", 'ě': "e",
+	'Ĝ': "G", 'ĝ': "g", 'Ğ': "G", 'ğ': "g", 'Ġ': "G", 'ġ': "g", 'Ģ': "G", 'ģ': "g",
+	'Ĥ': "H", 'ĥ': "h", 'Ħ': "H", 'ħ': "h",
+	'Ĩ': "I", 'ĩ': "i", 'Ī': "I", 'ī': "i", 'Ĭ': "I", 'ĭ': "i", 'Į': "I", 'į': "i", 'İ': "I", 'ı': "i",
+	'Ĳ': "IJ", 'ĳ': "ij", 'Ĵ': "J", 'ĵ': "j", 'Ķ': "K", 'ķ': "k", 'ĸ': "k",
+	'Ĺ': "L", 'ĺ': "l", 'Ļ': "L", 'ļ': "l", 'Ľ': "L", 'ľ': "l", 'Ŀ': "L", 'ŀ': "l", 'Ł': "L", 'ł': "l",
+	'Ń': "N", 'ń': "n", 'Ņ': "N", 'ņ': "n", 'Ň': "N", 'ň': "n", 'ŉ': "n", 'Ŋ': "NG", 'ŋ': "ng",
+	'Ō': "O", 'ō': "o", 'Ŏ': "O", 'ŏ': "o", 'Ő': "O", 'ő': "o", 'Œ': "OE", 'œ': "oe",
+	'Ŕ': "R", 'ŕ': "r", 'Ŗ': "R", 'ŗ': "r", 'Ř': "R", 'ř': "r",
+	'Ś': "S", 'ś': "s", 'Ŝ': "S", 'ŝ': "s", 'Ş': "S", 'ş': "s", 'Š': "S", 'š': "s",
+	'Ţ': "T", 'ţ': "t", 'Ť': "T", 'ť': "t", 'Ŧ': "T", 'ŧ': "t",
+	'Ũ': "U", 'ũ': "u", 'Ū': "U", 'ū': "u", 'Ŭ': "U", 'ŭ': "u", 'Ů': "U", 'ů': "u",
+	'Ű': "U", 'ű': "u", 'Ų': "U", 'ų': "u",
+	'Ŵ': "W", 'ŵ': "w", 'Ŷ': "Y", 'ŷ': "y", 'Ÿ': "Y",
+	'Ź': "Z", 'ź': "z", 'Ż': "Z", 'ż': "z", 'Ž': "Z", 'ž': "z", 'ſ': "s",
+
+	// Latin Extended-B (commonly used letters only)
+	'Ș': "S", 'ș': "s", 'Ț': "T", 'ț': "t", 'Ə': "E", 'ə': "e", 'ƒ': "f",
+}
+
+// cyrillicTable maps lower case Cyrillic letters to Latin. Upper case letters are derived in init.
+var cyrillicTable = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+
+	// Letters of other languages written in Cyrillic
+	'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "u",
+	'ђ': "dj", 'ј': "j", 'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz", 'ѓ': "gj", 'ќ': "kj", 'ѕ': "dz",
+}
+
+// greekTable maps lower case Greek letters to Latin. Upper case letters are derived in init.
+var greekTable = map[rune]string{
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+	'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o",
+	'ϊ': "i", 'ϋ': "y", 'ΐ': "i", 'ΰ': "y",
+}
+
+// localeTables contains the language specific rules which take precedence over the generic table. Keys
+// are primary language subtags, values map lower case letters; upper case letters are derived in init.
+var localeTables = map[string]map[rune]string{
+	"de": {'ä': "ae", 'ö': "oe", 'ü': "ue", 'ß': "ss", 'ẞ': "SS"},
+	"fr": {'à': "a", 'â': "a", 'æ': "ae", 'ç': "c", 'é': "e", 'è': "e", 'ê': "e", 'ë': "e",
+		'î': "i", 'ï': "i", 'ô': "o", 'œ': "oe", 'ù': "u", 'û': "u", 'ü': "u", 'ÿ': "y"},
+	"pl": {'ą': "a", 'ć': "c", 'ę': "e", 'ł': "l", 'ń': "n", 'ó': "o", 'ś': "s", 'ź': "z", 'ż': "z"},
+	"ru": {'е': "e", 'ё': "yo", 'й': "y", 'х': "kh", 'ц': "ts", 'щ': "shch", 'ъ': "", 'ы': "y",
+		'ь': "", 'э': "e", 'ю': "yu", 'я': "ya"},
+	"tr": {'ç': "c", 'ğ': "g", 'ı': "i", 'i': "i", 'ö': "o", 'ş': "s", 'ü': "u"},
+	// Ukrainian national transliteration (2010)
+	"uk": {'г': "h", 'ґ': "g", 'е': "e", 'є': "ie", 'и': "y", 'і': "i", 'ї': "i", 'й': "i",
+		'х': "kh", 'ц': "ts", 'щ': "shch", 'ь': "", 'ю': "iu", 'я': "ia", '\'': "", '’': "", 'ʼ': ""},
+}
+
+// localeInitialTables contains language specific rules for letters at the beginning of a word.
+var localeInitialTables = map[string]map[rune]string{
+	"uk": {'є': "ye", 'ї': "yi", 'й': "y", 'ю': "yu", 'я': "ya"},
+}
+
+// localeCases contains the language specific case mappings.
+var localeCases = map[string]unicode.SpecialCase{
+	"az": unicode.AzeriCase,
+	"tr": unicode.TurkishCase,
+}
+
+// translitRules is the set of rules used to transliterate the text of a language.
+type translitRules struct {
+	table   map[rune]string
+	initial map[rune]string
+	cases   unicode.SpecialCase
+}
+
+// genericRules are used for texts of unknown or unspecified language.
+var genericRules = &translitRules{table: map[rune]string{}}
+
+// localeRules contains the rules of all languages with a table in localeTables or localeCases.
+var localeRules = map[string]*translitRules{}
+
+// init builds genericRules out of the script tables and the rules of each language on top of them.
+func init() {
+	for r, s := range latinTable {
+		genericRules.table[r] = s
+	}
+	for _, table := range []map[rune]string{cyrillicTable, greekTable} {
+		addWithUpper(genericRules.table, table)
+	}
+
+	for lang, cases := range localeCases {
+		localeRules[lang] = &translitRules{table: genericRules.table, cases: cases}
+	}
+	for lang, overrides := range localeTables {
+		rules := &translitRules{table: make(map[rune]string), cases: localeCases[lang]}
+		for r, s := range genericRules.table {
+			rules.table[r] = s
+		}
+		addWithUpper(rules.table, overrides)
+		if initial, ok := localeInitialTables[lang]; ok {
+			rules.initial = make(map[rune]string)
+			addWithUpper(rules.initial, initial)
+		}
+		localeRules[lang] = rules
+	}
+}
+
+// addWithUpper copies table into dst and adds the upper case variant of each lower case letter.
+func addWithUpper(dst, table map[rune]string) {
+	for r, s := range table {
+		dst[r] = s
+	}
+	for r, s := range table {
+		if upper := unicode.ToUpper(r); upper != r {
+			if _, ok := table[upper]; !ok {
+				dst[upper] = capitalize(s)
+			}
+		}
+	}
+}
+
+// capitalize upper cases the first letter of s.
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// rulesFor returns the transliteration rules for a language tag such as "de", "de-AT" or "pt_BR".
+// Unknown languages get the generic rules.
+func rulesFor(lang string) *translitRules {
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if rules, ok := localeRules[lang]; ok {
+		return rules
+	}
+	return genericRules
+}
+
+// toLower lower cases s with the case mapping of the rules' language.
+func (rules *translitRules) toLower(s string) string {
+	if rules.cases != nil {
+		return strings.ToLowerSpecial(rules.cases, s)
+	}
+	return strings.ToLower(s)
+}
+
+// Transliterate replaces Latin letters with diacritics, German ß and umlauts, Cyrillic and Greek letters
+// by their closest ASCII spelling. Combining marks are dropped; all other characters are kept as they are.
+// If Language is set, the rules of that language are applied (see TransliterateLang).
+func (t *Tools) Transliterate(s string) string {
+	return transliterate(s, rulesFor(t.Language))
+}
+
+// TransliterateLang works like Transliterate, but applies the rules of the language given by the tag lang,
+// e.g. "ä" becomes "ae" for German. There are specific rules for de, fr, pl, ru, tr and uk; other languages
+// use the generic rules.
+func (t *Tools) TransliterateLang(s, lang string) string {
+	return transliterate(s, rulesFor(lang))
+}
+
+// transliterate replaces every rune of s found in the rules' tables and drops combining marks.
+func transliterate(s string, rules *translitRules) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	inWord := false
+	for _, r := range s {
+		repl, ok := rules.initial[r]
+		if !ok || inWord {
+			repl, ok = rules.table[r]
+		}
+		inWord = unicode.IsLetter(r) || unicode.Is(unicode.Mn, r) || (inWord && ok && repl == "")
+
+		if ok {
+			b.WriteString(repl)
+			continue
+		}
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
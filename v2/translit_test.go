@@ -0,0 +1,90 @@
+package toolkit
+
+import "testing"
+
+func TestTransliterate(t *testing.T) {
+	tools := Tools{}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "ascii", in: "Hello World", want: "Hello World"},
+		{name: "french", in: "Crème Brûlée à la façon", want: "Creme Brulee a la facon"},
+		{name: "german", in: "Äpfel über Straße", want: "Apfel uber Strasse"},
+		{name: "polish", in: "Łódź Żółć", want: "Lodz Zolc"},
+		{name: "ligatures", in: "Œuvre Æsir", want: "OEuvre AEsir"},
+		{name: "russian", in: "Щука и Ёж", want: "Shchuka i Yozh"},
+		{name: "ukrainian", in: "Київ", want: "Kiyiv"},
+		{name: "greek", in: "Αθήνα", want: "Athina"},
+		{name: "combining marks", in: "Cafe\u0301", want: "Cafe"},
+		{name: "untouched", in: "日本", want: "日本"},
+	}
+
+	for _, test := range tests {
+		got := tools.Transliterate(test.in)
+		if got != test.want {
+			t.Errorf("%s - expected %q, got %q\n", test.name, test.want, got)
+		}
+	}
+}
+
+func TestTransliterateLang(t *testing.T) {
+	tools := Tools{}
+
+	tests := []struct {
+		lang string
+		in   string
+		want string
+	}{
+		{lang: "", in: "Müller Straße", want: "Muller Strasse"},
+		{lang: "de", in: "Müller Straße", want: "Mueller Strasse"},
+		{lang: "de-AT", in: "Ärger Öl Über", want: "Aerger Oel Ueber"},
+		{lang: "tr", in: "Işık İstanbul Çağ Şişli", want: "Isik Istanbul Cag Sisli"},
+		{lang: "ru", in: "Щёлково Юрий", want: "Shchyolkovo Yuriy"},
+		{lang: "uk", in: "Київ Гоголь", want: "Kyiv Hohol"},
+		{lang: "uk_UA", in: "Юрій Яблуня Зв'язок", want: "Yurii Yablunia Zviazok"},
+		{lang: "pl", in: "Łódź Gdańsk Źródło", want: "Lodz Gdansk Zrodlo"},
+		{lang: "fr", in: "Cœur Noël Garçon", want: "Coeur Noel Garcon"},
+		{lang: "xx", in: "Müller", want: "Muller"},
+	}
+
+	for _, test := range tests {
+		got := tools.TransliterateLang(test.in, test.lang)
+		if got != test.want {
+			t.Errorf("%s - expected %q, got %q\n", test.lang, test.want, got)
+		}
+	}
+}
+
+func TestSlugifyLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		tools    Tools
+		sentence string
+		opts     SlugOptions
+		slug     string
+	}{
+		{name: "de option", sentence: "Grüße aus Köln", opts: SlugOptions{Language: "de"}, slug: "gruesse-aus-koeln"},
+		{name: "de tools", tools: Tools{Language: "de"}, sentence: "Grüße aus Köln", slug: "gruesse-aus-koeln"},
+		{name: "option over tools", tools: Tools{Language: "de"}, sentence: "Köln", opts: SlugOptions{Language: "fr"}, slug: "koln"},
+		{name: "tr", sentence: "IŞIK Ilıca", opts: SlugOptions{Language: "tr"}, slug: "isik-ilica"},
+		{name: "tr unicode", sentence: "İSTANBUL IRMAK", opts: SlugOptions{Language: "tr", PreserveUnicode: true}, slug: "istanbul-ırmak"},
+		{name: "ru", sentence: "Горький парк", opts: SlugOptions{Language: "ru"}, slug: "gorkiy-park"},
+		{name: "uk", sentence: "Гаряча їжа", opts: SlugOptions{Language: "uk"}, slug: "hariacha-yizha"},
+		{name: "pl", sentence: "Zażółć gęślą jaźń", opts: SlugOptions{Language: "pl"}, slug: "zazolc-gesla-jazn"},
+		{name: "fr", sentence: "Œuvre complète", opts: SlugOptions{Language: "fr"}, slug: "oeuvre-complete"},
+	}
+
+	for _, test := range tests {
+		got, err := test.tools.SlugifyWithOptions(test.sentence, test.opts)
+		if err != nil {
+			t.Errorf("%s - received error %v\n", test.name, err)
+			continue
+		}
+		if got != test.slug {
+			t.Errorf("%s - expected %s, got %s\n", test.name, test.slug, got)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package toolkit
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError describes a field which violates a validation rule. Field is the path of the field using
+// the JSON names, e.g. "address.street" or "items[2].name".
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface.
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// ValidationErrors is returned by Validate and contains all violations found.
+type ValidationErrors []FieldError
+
+// Error implements the error interface.
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, e := range v {
+		messages[i] = e.Error()
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// validationRegexps caches the compiled patterns of regex rules.
+var validationRegexps sync.Map
+
+// Validate checks data against the rules in the validate tags of its struct fields and returns all
+// violations at once as ValidationErrors. Rules are separated by commas:
+//
+//	required   the field must not have its zero value (pointers must not be nil)
+//	omitempty  skip all other rules if the field has its zero value
+//	min=n      minimum value of numbers, minimum length of strings (in characters), slices and maps
+//	max=n      maximum value or length
+//	len=n      exact length of strings, slices and maps
+//	email      the string must be an email address like "jane@example.com"
+//	url        the string must be an absolute URL
+//	oneof=a b  the value must be one of the values separated by blanks
+//	regex=re   the string must match the regular expression; must be the last rule of the tag
+//
+// Nested structs, pointers to structs as well as slices, arrays and maps of structs are validated, too.
+// A malformed tag results in an error that is not of type ValidationErrors.
+func (t *Tools) Validate(data interface{}) error {
+	var errs ValidationErrors
+	if err := validateValue(reflect.ValueOf(data), "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateValue descends into structs and collections and validates the fields of all structs it finds.
+func validateValue(v reflect.Value, path string, errs *ValidationErrors) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return validateStruct(v, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := validateValue(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateStruct applies the rules of each field of the struct v and descends into the field values.
+func validateStruct(v reflect.Value, path string, errs *ValidationErrors) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+
+		fieldPath := path
+		// Fields of embedded structs are promoted to the outer struct like encoding/json does.
+		if !(field.Anonymous && field.Tag.Get("json") == "") {
+			fieldPath = joinFieldPath(path, name)
+		}
+
+		if tag := field.Tag.Get("validate"); tag != "" && tag != "-" {
+			if err := applyRules(v.Field(i), fieldPath, tag, errs); err != nil {
+				return err
+			}
+		}
+		if err := validateValue(v.Field(i), fieldPath, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonFieldName returns the name of field in JSON and whether the field is skipped by encoding/json.
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, false
+}
+
+// joinFieldPath appends name to path with a dot.
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// applyRules checks the value v of a field against the rules of its tag.
+func applyRules(v reflect.Value, path, tag string, errs *ValidationErrors) error {
+	rules := splitRules(tag)
+
+	for _, rule := range rules {
+		if rule == "omitempty" && v.IsZero() {
+			return nil
+		}
+	}
+
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "omitempty" {
+			continue
+		}
+
+		msg, err := checkRule(v, name, param)
+		if err != nil {
+			return fmt.Errorf("validate: field %s: %w", path, err)
+		}
+		if msg != "" {
+			*errs = append(*errs, FieldError{Field: path, Rule: name, Param: param, Message: msg})
+			if name == "required" {
+				// Further rules would only repeat that the value is missing.
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// splitRules splits a validate tag into its rules. Everything after "regex=" belongs to the pattern.
+func splitRules(tag string) []string {
+	var rules []string
+	for tag != "" {
+		if strings.HasPrefix(tag, "regex=") {
+			return append(rules, tag)
+		}
+		var rule string
+		rule, tag, _ = strings.Cut(tag, ",")
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// checkRule returns a message if v violates the rule or an error if the rule is malformed.
+func checkRule(v reflect.Value, rule, param string) (string, error) {
+	deref := v
+	for deref.Kind() == reflect.Pointer || deref.Kind() == reflect.Interface {
+		if deref.IsNil() {
+			if rule == "required" {
+				return "is required", nil
+			}
+			return "", nil
+		}
+		deref = deref.Elem()
+	}
+
+	switch rule {
+	case "required":
+		if v.IsZero() {
+			return "is required", nil
+		}
+		return "", nil
+
+	case "min", "max", "len":
+		return checkBound(deref, rule, param)
+
+	case "email":
+		s, err := stringValue(deref, rule)
+		if err != nil {
+			return "", err
+		}
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+			return "must be a valid email address", nil
+		}
+		return "", nil
+
+	case "url":
+		s, err := stringValue(deref, rule)
+		if err != nil {
+			return "", err
+		}
+		if u, err := url.ParseRequestURI(s); err != nil || u.Scheme == "" || u.Host == "" {
+			return "must be a valid URL", nil
+		}
+		return "", nil
+
+	case "oneof":
+		value := fmt.Sprint(deref.Interface())
+		for _, allowed := range strings.Fields(param) {
+			if value == allowed {
+				return "", nil
+			}
+		}
+		return fmt.Sprintf("must be one of %s", strings.Join(strings.Fields(param), ", ")), nil
+
+	case "regex":
+		s, err := stringValue(deref, rule)
+		if err != nil {
+			return "", err
+		}
+		re, err := compileValidationRegexp(param)
+		if err != nil {
+			return "", err
+		}
+		if !re.MatchString(s) {
+			return fmt.Sprintf("must match the pattern %s", param), nil
+		}
+		return "", nil
+	}
+
+	return "", fmt.Errorf("unknown rule %q", rule)
+}
+
+// checkBound implements the min, max and len rules.
+func checkBound(v reflect.Value, rule, param string) (string, error) {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid parameter %q for rule %s", param, rule)
+	}
+
+	var actual float64
+	verb, unit := "must be", ""
+	switch v.Kind() {
+	case reflect.String:
+		actual, unit = float64(utf8.RuneCountInString(v.String())), " characters long"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		actual, verb, unit = float64(v.Len()), "must contain", " elements"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		actual = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = v.Float()
+	default:
+		return "", fmt.Errorf("rule %s is not applicable to %s", rule, v.Kind())
+	}
+
+	switch rule {
+	case "len":
+		if unit == "" {
+			return "", fmt.Errorf("rule len is not applicable to %s", v.Kind())
+		}
+		if actual != limit {
+			return fmt.Sprintf("%s exactly %s%s", verb, param, unit), nil
+		}
+	case "min":
+		if actual < limit {
+			return fmt.Sprintf("%s at least %s%s", verb, param, unit), nil
+		}
+	case "max":
+		if actual > limit {
+			return fmt.Sprintf("%s at most %s%s", verb, param, unit), nil
+		}
+	}
+	return "", nil
+}
+
+// stringValue returns the string v or an error if rule is not applicable to v.
+func stringValue(v reflect.Value, rule string) (string, error) {
+	if v.Kind() != reflect.String {
+		return "", fmt.Errorf("rule %s is not applicable to %s", rule, v.Kind())
+	}
+	return v.String(), nil
+}
+
+// compileValidationRegexp compiles pattern once and caches the result.
+func compileValidationRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := validationRegexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	validationRegexps.Store(pattern, re)
+	return re, nil
+}
@@ -0,0 +1,180 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type validateAddress struct {
+	Street string `json:"street" validate:"required"`
+	Zip    string `json:"zip" validate:"len=5,regex=^[0-9]+$"`
+}
+
+type validateItem struct {
+	Name     string `json:"name" validate:"required,max=5"`
+	Quantity int    `json:"quantity" validate:"min=1,max=10"`
+}
+
+type validateUser struct {
+	Name     string            `json:"name" validate:"required,min=3,max=10"`
+	Email    string            `json:"email" validate:"required,email"`
+	Website  string            `json:"website,omitempty" validate:"omitempty,url"`
+	Role     string            `json:"role" validate:"oneof=admin editor viewer"`
+	Age      *int              `json:"age" validate:"required,min=18"`
+	Tags     []string          `json:"tags" validate:"max=2"`
+	Address  validateAddress   `json:"address"`
+	Billing  *validateAddress  `json:"billing"`
+	Items    []validateItem    `json:"items" validate:"min=1"`
+	Internal string            `json:"-" validate:"required"`
+	Extra    map[string]string `json:"extra"`
+}
+
+func TestValidate(t *testing.T) {
+	tools := Tools{}
+	age, young := 30, 16
+
+	valid := validateUser{
+		Name:    "Jane",
+		Email:   "jane@example.com",
+		Website: "https://example.com",
+		Role:    "editor",
+		Age:     &age,
+		Address: validateAddress{Street: "Main St", Zip: "12345"},
+		Items:   []validateItem{{Name: "pen", Quantity: 2}},
+	}
+	if err := tools.Validate(valid); err != nil {
+		t.Errorf("Expected valid user, got %v\n", err)
+	}
+	if err := tools.Validate(&valid); err != nil {
+		t.Errorf("Expected valid user pointer, got %v\n", err)
+	}
+
+	invalid := validateUser{
+		Name:    "Jo",
+		Email:   "Jane <jane@example.com>",
+		Website: "not a url",
+		Role:    "owner",
+		Age:     &young,
+		Tags:    []string{"a", "b", "c"},
+		Address: validateAddress{Zip: "12a45"},
+		Billing: &validateAddress{Street: "Side St", Zip: "123"},
+		Items:   []validateItem{{Name: "pencil", Quantity: 0}},
+	}
+	err := tools.Validate(invalid)
+
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("Expected ValidationErrors, got %v\n", err)
+	}
+
+	want := map[string]string{
+		"name":              "min",
+		"email":             "email",
+		"website":           "url",
+		"role":              "oneof",
+		"age":               "min",
+		"tags":              "max",
+		"address.street":    "required",
+		"address.zip":       "regex",
+		"billing.zip":       "len",
+		"items[0].name":     "max",
+		"items[0].quantity": "min",
+	}
+	got := make(map[string]string)
+	for _, e := range verrs {
+		got[e.Field] = e.Rule
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected field errors\n got: %v\nwant: %v\n", got, want)
+	}
+}
+
+func TestValidateMessages(t *testing.T) {
+	tools := Tools{}
+
+	tests := []struct {
+		name string
+		data interface{}
+		want string
+	}{
+		{name: "required", data: struct {
+			A string `json:"a" validate:"required,min=2"`
+		}{}, want: "a is required"},
+		{name: "string length", data: struct {
+			A string `validate:"min=3"`
+		}{A: "äö"}, want: "A must be at least 3 characters long"},
+		{name: "number", data: struct {
+			A float64 `json:"a" validate:"max=1.5"`
+		}{A: 2}, want: "a must be at most 1.5"},
+		{name: "elements", data: struct {
+			A []int `json:"a" validate:"len=2"`
+		}{A: []int{1}}, want: "a must contain exactly 2 elements"},
+		{name: "nil pointer", data: struct {
+			A *string `json:"a" validate:"required"`
+		}{}, want: "a is required"},
+		{name: "regex with comma", data: struct {
+			A string `json:"a" validate:"required,regex=^a{1,2}$"`
+		}{A: "aaa"}, want: "a must match the pattern ^a{1,2}$"},
+	}
+
+	for _, test := range tests {
+		err := tools.Validate(test.data)
+		var verrs ValidationErrors
+		if !errors.As(err, &verrs) || len(verrs) != 1 {
+			t.Errorf("%s: expected one field error, got %v\n", test.name, err)
+			continue
+		}
+		if verrs[0].Error() != test.want {
+			t.Errorf("%s: expected %q, got %q\n", test.name, test.want, verrs[0].Error())
+		}
+	}
+}
+
+func TestValidateMalformedTag(t *testing.T) {
+	tools := Tools{}
+
+	tests := []interface{}{
+		struct {
+			A string `validate:"unknown"`
+		}{},
+		struct {
+			A string `validate:"min=abc"`
+		}{},
+		struct {
+			A int `validate:"email"`
+		}{},
+		struct {
+			A string `validate:"regex=("`
+		}{},
+	}
+
+	for i, data := range tests {
+		err := tools.Validate(data)
+		var verrs ValidationErrors
+		if err == nil || errors.As(err, &verrs) {
+			t.Errorf("%d: expected a tag error, got %v\n", i, err)
+		}
+	}
+}
+
+func TestReadJSONValidate(t *testing.T) {
+	tools := Tools{ValidateJSON: true}
+
+	body := `{"name": "Jane", "quantity": 20}`
+	var item validateItem
+	err := tools.ReadJSON(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(body)), &item)
+
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].Field != "quantity" {
+		t.Errorf("Expected a quantity violation, got %v\n", err)
+	}
+
+	body = `[{"name": "pen", "quantity": 1}, {"name": "", "quantity": 1}]`
+	_, err = ReadJSONArray[validateItem](&tools, httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(body)), 10)
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].Field != "[1].name" {
+		t.Errorf("Expected a [1].name violation, got %v\n", err)
+	}
+}
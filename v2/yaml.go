@@ -0,0 +1,876 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// yamlCodec encodes application/yaml (RFC 9512). The decoder understands the subset of YAML 1.2 that is
+// used for data: block and flow collections, plain, quoted and block scalars and comments. Anchors,
+// aliases, tags, complex keys and multiple documents are rejected.
+type yamlCodec struct{}
+
+func (yamlCodec) MediaTypes() []string {
+	return []string{"application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml"}
+}
+
+func (yamlCodec) Encode(w io.Writer, v interface{}) error {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := encodeYAML(&buf, generic, 0, false); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (yamlCodec) Decode(r io.Reader, v interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	generic, err := decodeYAML(string(data))
+	if err != nil {
+		return err
+	}
+	return fromGeneric(generic, v)
+}
+
+var (
+	yamlPlainSafeRegexp = regexp.MustCompile(`^[\pL\pN_./][\pL\pN_./ -]*$`)
+	yamlIntRegexp       = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlHexRegexp       = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	yamlOctalRegexp     = regexp.MustCompile(`^0o[0-7]+$`)
+	yamlFloatRegexp     = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// encodeYAML writes a value produced by toGeneric in block style. If inline is set, the first line
+// continues the current line, as after "- ".
+func encodeYAML(buf *bytes.Buffer, v interface{}, indent int, inline bool) error {
+	pad := strings.Repeat(" ", indent)
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			break
+		}
+		for i, k := range sortedKeys(v) {
+			if i > 0 || !inline {
+				buf.WriteString(pad)
+			}
+			buf.WriteString(yamlString(k))
+			buf.WriteByte(':')
+
+			var err error
+			switch {
+			case isNonEmptyMap(v[k]):
+				buf.WriteByte('\n')
+				err = encodeYAML(buf, v[k], indent+2, false)
+			case isNonEmptyList(v[k]):
+				// Sequences are indented like their key.
+				buf.WriteByte('\n')
+				err = encodeYAML(buf, v[k], indent, false)
+			default:
+				buf.WriteByte(' ')
+				err = encodeYAML(buf, v[k], indent, true)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case []interface{}:
+		if len(v) == 0 {
+			break
+		}
+		for i, item := range v {
+			if i > 0 || !inline {
+				buf.WriteString(pad)
+			}
+			// Collections start behind the dash.
+			buf.WriteString("- ")
+			if err := encodeYAML(buf, item, indent+2, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	scalar, err := yamlScalar(v)
+	if err != nil {
+		return err
+	}
+	buf.WriteString(scalar)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// isNonEmptyMap reports whether v is a map with entries.
+func isNonEmptyMap(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	return ok && len(m) > 0
+}
+
+// isNonEmptyList reports whether v is a slice with items.
+func isNonEmptyList(v interface{}) bool {
+	l, ok := v.([]interface{})
+	return ok && len(l) > 0
+}
+
+// yamlScalar formats a scalar or an empty collection.
+func yamlScalar(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return string(v), nil
+	case string:
+		return yamlString(v), nil
+	case map[string]interface{}:
+		return "{}", nil
+	case []interface{}:
+		return "[]", nil
+	}
+	return "", fmt.Errorf("yaml: unsupported type %T", v)
+}
+
+// yaml11Words are plain scalars that are booleans or null in YAML 1.1, which many parsers still
+// implement, but strings in YAML 1.2. They are quoted, so that both read them as strings.
+var yaml11Words = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": true, "N": true, "no": true, "No": true, "NO": true,
+	"on": true, "On": true, "ON": true, "off": true, "Off": true, "OFF": true,
+}
+
+// yamlString returns s as plain scalar if that is unambiguous, otherwise double quoted. JSON strings
+// are valid double quoted YAML scalars.
+func yamlString(s string) string {
+	if yamlPlainSafeRegexp.MatchString(s) && !strings.HasSuffix(s, " ") && !yaml11Words[s] {
+		if resolved, _ := resolveYAMLScalar(s); resolved == s {
+			return s
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// resolveYAMLScalar returns the value of a plain scalar according to the YAML 1.2 core schema.
+func resolveYAMLScalar(s string) (interface{}, error) {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF", "-.inf", "-.Inf", "-.INF", ".nan", ".NaN", ".NAN":
+		return nil, fmt.Errorf("yaml: %s can't be represented", s)
+	}
+
+	switch {
+	case yamlIntRegexp.MatchString(s):
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(strings.TrimPrefix(s, "+"), 10, 64); err == nil {
+			return u, nil
+		}
+		return strconv.ParseFloat(s, 64)
+	case yamlHexRegexp.MatchString(s):
+		return strconv.ParseUint(s[2:], 16, 64)
+	case yamlOctalRegexp.MatchString(s):
+		return strconv.ParseUint(s[2:], 8, 64)
+	case yamlFloatRegexp.MatchString(s):
+		return strconv.ParseFloat(s, 64)
+	}
+	return s, nil
+}
+
+// yamlLine is a line of a YAML document without its indentation.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// yamlParser parses YAML documents line by line.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// yamlError is a parse error at a line of the document.
+func yamlError(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("yaml: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// decodeYAML parses a single YAML document into maps, slices, strings, numbers, bools and nils.
+func decodeYAML(doc string) (interface{}, error) {
+	if !utf8.ValidString(doc) {
+		return nil, errors.New("yaml: document is not valid UTF-8")
+	}
+	doc = strings.TrimPrefix(doc, "\ufeff")
+
+	p := &yamlParser{}
+	for i, text := range strings.Split(strings.TrimSuffix(doc, "\n"), "\n") {
+		text = strings.TrimSuffix(text, "\r")
+		trimmed := strings.TrimLeft(text, " ")
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+
+	// Skip directives and the start of the document.
+	p.skipBlank()
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == 0 && strings.HasPrefix(p.lines[p.pos].text, "%") {
+		p.pos++
+		p.skipBlank()
+	}
+	if p.pos < len(p.lines) && isYAMLDocumentMarker(p.lines[p.pos], "---") {
+		l := p.lines[p.pos]
+		if rest := strings.TrimLeft(l.text[3:], " "); rest != "" && rest[0] != '#' {
+			p.lines[p.pos] = yamlLine{num: l.num, indent: len(l.text) - len(rest), text: rest}
+		} else {
+			p.pos++
+		}
+	}
+
+	value, err := p.parseBlock(0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		switch {
+		case isYAMLDocumentMarker(l, "..."):
+		case isYAMLDocumentMarker(l, "---"):
+			return nil, yamlError(l.num, "multiple documents are not supported")
+		default:
+			return nil, yamlError(l.num, "unexpected content")
+		}
+	}
+	return value, nil
+}
+
+// isYAMLDocumentMarker reports whether l is the marker "---" or "...".
+func isYAMLDocumentMarker(l yamlLine, marker string) bool {
+	return l.indent == 0 && strings.HasPrefix(l.text, marker) && (len(l.text) == 3 || l.text[3] == ' ' || l.text[3] == '\t')
+}
+
+// isYAMLBlank reports whether text is empty or a comment.
+func isYAMLBlank(text string) bool {
+	trimmed := strings.TrimSpace(text)
+	return trimmed == "" || trimmed[0] == '#'
+}
+
+// isYAMLSequenceEntry reports whether text starts with a block sequence indicator.
+func isYAMLSequenceEntry(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ") || strings.HasPrefix(text, "-\t")
+}
+
+// skipBlank moves behind empty and comment lines.
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && isYAMLBlank(p.lines[p.pos].text) {
+		p.pos++
+	}
+}
+
+// next returns the next non-blank line of the current document, if it is indented at least minIndent.
+func (p *yamlParser) next(minIndent int) (yamlLine, bool, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return yamlLine{}, false, nil
+	}
+	l := p.lines[p.pos]
+	if l.indent < minIndent || isYAMLDocumentMarker(l, "---") || isYAMLDocumentMarker(l, "...") {
+		return yamlLine{}, false, nil
+	}
+	if l.text[0] == '\t' {
+		return yamlLine{}, false, yamlError(l.num, "tabs are not allowed for indentation")
+	}
+	return l, true, nil
+}
+
+// parseBlock parses the node starting at the next line if it is indented at least minIndent. Otherwise
+// the node is empty, i.e. null.
+func (p *yamlParser) parseBlock(minIndent, depth int) (interface{}, error) {
+	if depth > maxDecodeDepth {
+		return nil, errDecodeDepth
+	}
+
+	l, ok, err := p.next(minIndent)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	if isYAMLSequenceEntry(l.text) {
+		return p.parseSequence(l.indent, depth)
+	}
+	if _, _, isEntry, err := splitYAMLMappingEntry(l); err != nil {
+		return nil, err
+	} else if isEntry {
+		return p.parseMapping(l.indent, depth)
+	}
+
+	p.pos++
+	return p.parseValue(l.text, l, l.indent-1, depth, false)
+}
+
+// parseMapping parses a block mapping whose keys are indented by indent.
+func (p *yamlParser) parseMapping(indent, depth int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for {
+		l, ok, err := p.next(indent)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return m, nil
+		}
+		if l.indent > indent {
+			return nil, yamlError(l.num, "unexpected indentation")
+		}
+
+		key, rest, isEntry, err := splitYAMLMappingEntry(l)
+		if err != nil {
+			return nil, err
+		}
+		if !isEntry {
+			if isYAMLSequenceEntry(l.text) {
+				return m, nil
+			}
+			return nil, yamlError(l.num, "expected a mapping key")
+		}
+		if _, duplicate := m[key]; duplicate {
+			return nil, yamlError(l.num, "duplicate key %q", key)
+		}
+
+		p.pos++
+		if m[key], err = p.parseValue(rest, l, indent, depth+1, true); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseSequence parses a block sequence whose dashes are indented by indent.
+func (p *yamlParser) parseSequence(indent, depth int) (interface{}, error) {
+	list := []interface{}{}
+	for {
+		l, ok, err := p.next(indent)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return list, nil
+		}
+		if l.indent > indent {
+			return nil, yamlError(l.num, "unexpected indentation")
+		}
+		if !isYAMLSequenceEntry(l.text) {
+			return list, nil
+		}
+
+		var item interface{}
+		content := strings.TrimLeft(l.text[1:], " \t")
+		if isYAMLBlank(content) {
+			p.pos++
+			item, err = p.parseValue("", l, indent, depth+1, false)
+		} else {
+			// Parse the content behind the dash as if it were a line of its own, so that a mapping can
+			// continue on the following lines.
+			offset := len(l.text) - len(content)
+			p.lines[p.pos] = yamlLine{num: l.num, indent: indent + offset, text: content}
+			item, err = p.parseBlock(indent+offset, depth+1)
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+}
+
+// parseValue parses text, the value behind a key or dash on line l. If it is empty, the value is the
+// block on the following lines that is indented more than parentIndent. In mappings a sequence may be
+// indented like its key.
+func (p *yamlParser) parseValue(text string, l yamlLine, parentIndent, depth int, inMapping bool) (interface{}, error) {
+	text = strings.TrimLeft(text, " \t")
+	if isYAMLBlank(text) {
+		next, ok, err := p.next(parentIndent)
+		if err != nil || !ok {
+			return nil, err
+		}
+		if next.indent > parentIndent || (inMapping && isYAMLSequenceEntry(next.text)) {
+			return p.parseBlock(next.indent, depth)
+		}
+		return nil, nil
+	}
+
+	switch text[0] {
+	case '|', '>':
+		return p.parseBlockScalar(text, l, parentIndent)
+
+	case '[', '{':
+		// Flow collections may span several lines.
+		for !yamlFlowComplete(text) && p.pos < len(p.lines) {
+			text += "\n" + p.lines[p.pos].text
+			p.pos++
+		}
+		f := &yamlFlow{s: text, line: l.num}
+		value, err := f.value(depth)
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpace()
+		if f.pos < len(f.s) {
+			return nil, yamlError(l.num, "unexpected content after flow collection")
+		}
+		return value, nil
+
+	case '"', '\'':
+		value, rest, err := yamlQuoted(text, l.num)
+		if err != nil {
+			return nil, err
+		}
+		if !isYAMLBlank(rest) {
+			return nil, yamlError(l.num, "unexpected content after quoted scalar")
+		}
+		return value, nil
+
+	case '&', '*', '!':
+		return nil, yamlError(l.num, "anchors, aliases and tags are not supported")
+
+	case '@', '`':
+		return nil, yamlError(l.num, "reserved indicator %q", text[0])
+	}
+
+	if i := strings.Index(text, " #"); i >= 0 {
+		text = text[:i]
+	}
+	text = strings.TrimRight(text, " \t")
+	if strings.Contains(text, ": ") || strings.HasSuffix(text, ":") {
+		return nil, yamlError(l.num, "mapping values are not allowed here")
+	}
+
+	value, err := resolveYAMLScalar(text)
+	if err != nil {
+		return nil, yamlError(l.num, "%v", err)
+	}
+	return value, nil
+}
+
+// splitYAMLMappingEntry splits a line like "key: value" into the key and the value text.
+func splitYAMLMappingEntry(l yamlLine) (key, rest string, ok bool, err error) {
+	text := l.text
+	switch text[0] {
+	case '"', '\'':
+		key, rest, err = yamlQuoted(text, l.num)
+		if err != nil {
+			return "", "", false, err
+		}
+		rest = strings.TrimLeft(rest, " \t")
+		if rest == ":" || strings.HasPrefix(rest, ": ") || strings.HasPrefix(rest, ":\t") {
+			return key, rest[1:], true, nil
+		}
+		return "", "", false, nil
+
+	case '?':
+		if text == "?" || text[1] == ' ' {
+			return "", "", false, yamlError(l.num, "complex keys are not supported")
+		}
+
+	case '[', '{', '#', '|', '>':
+		return "", "", false, nil
+	}
+
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '#' && i > 0 && (text[i-1] == ' ' || text[i-1] == '\t'):
+			return "", "", false, nil
+		case text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t'):
+			key = strings.TrimRight(text[:i], " \t")
+			if key == "" {
+				return "", "", false, nil
+			}
+			if strings.ContainsAny(key[:1], "&*!") {
+				return "", "", false, yamlError(l.num, "anchors, aliases and tags are not supported")
+			}
+			return key, text[i+1:], true, nil
+		}
+	}
+	return "", "", false, nil
+}
+
+// parseBlockScalar parses a literal (|) or folded (>) block scalar with the header text.
+func (p *yamlParser) parseBlockScalar(header string, l yamlLine, parentIndent int) (interface{}, error) {
+	style := header[0]
+	var chomp byte
+	contentIndent := -1
+	indicators := header[1:]
+	if i := strings.Index(indicators, "#"); i >= 0 {
+		indicators = indicators[:i]
+	}
+	for _, c := range strings.TrimRight(indicators, " \t") {
+		switch {
+		case (c == '-' || c == '+') && chomp == 0:
+			chomp = byte(c)
+		case c >= '1' && c <= '9' && contentIndent < 0:
+			contentIndent = parentIndent + int(c-'0')
+			if contentIndent < 0 {
+				contentIndent = 0
+			}
+		default:
+			return nil, yamlError(l.num, "invalid block scalar header %q", header)
+		}
+	}
+
+	var lines []string
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line.text) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		if contentIndent < 0 {
+			if line.indent <= parentIndent {
+				break
+			}
+			contentIndent = line.indent
+		}
+		if line.indent < contentIndent {
+			break
+		}
+		lines = append(lines, strings.Repeat(" ", line.indent-contentIndent)+line.text)
+		p.pos++
+	}
+
+	n := len(lines)
+	for n > 0 && lines[n-1] == "" {
+		n--
+	}
+	body, trailing := lines[:n], len(lines)-n
+
+	var text string
+	if style == '|' {
+		text = strings.Join(body, "\n")
+	} else {
+		text = foldYAMLLines(body)
+	}
+
+	switch chomp {
+	case '-':
+	case '+':
+		if n > 0 {
+			text += "\n"
+		}
+		text += strings.Repeat("\n", trailing)
+	default:
+		if n > 0 {
+			text += "\n"
+		}
+	}
+	return text, nil
+}
+
+// foldYAMLLines joins the lines of a folded block scalar. Line breaks between lines become spaces,
+// empty lines become line breaks. More indented lines keep their line breaks.
+func foldYAMLLines(lines []string) string {
+	var b strings.Builder
+	blank := 0
+	started := false
+	for i, line := range lines {
+		if line == "" {
+			blank++
+			continue
+		}
+		switch {
+		case !started:
+			b.WriteString(strings.Repeat("\n", blank))
+		case blank > 0:
+			b.WriteString(strings.Repeat("\n", blank))
+		case line[0] == ' ' || lines[i-1][0] == ' ':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(' ')
+		}
+		b.WriteString(line)
+		blank = 0
+		started = true
+	}
+	return b.String()
+}
+
+// yamlQuoted parses the single or double quoted scalar at the start of s and returns it with the rest
+// of s. Line breaks within the scalar are folded into spaces.
+func yamlQuoted(s string, line int) (string, string, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			b.WriteByte('\'')
+			i++
+		case c == quote:
+			return b.String(), s[i+1:], nil
+		case c == '\n':
+			for i+1 < len(s) && (s[i+1] == ' ' || s[i+1] == '\t') {
+				i++
+			}
+			b.WriteByte(' ')
+		case c == '\\' && quote == '"':
+			if i+1 == len(s) {
+				return "", "", yamlError(line, "unterminated quoted scalar")
+			}
+			n, err := yamlEscape(&b, s[i+1:], line)
+			if err != nil {
+				return "", "", err
+			}
+			i += n
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", yamlError(line, "unterminated quoted scalar")
+}
+
+// yamlEscapes are the single character escapes of double quoted scalars.
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f", 'r': "\r",
+	'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+	'P': "\u2029",
+}
+
+// yamlEscape writes the character of the escape sequence at the start of s (behind the backslash) and
+// returns the number of bytes it takes.
+func yamlEscape(b *strings.Builder, s string, line int) (int, error) {
+	if r, ok := yamlEscapes[s[0]]; ok {
+		b.WriteString(r)
+		return 1, nil
+	}
+
+	size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[0]]
+	if size == 0 || len(s) <= size {
+		return 0, yamlError(line, "invalid escape sequence \\%c", s[0])
+	}
+	code, err := strconv.ParseUint(s[1:1+size], 16, 32)
+	if err != nil || code > utf8.MaxRune {
+		return 0, yamlError(line, "invalid escape sequence \\%s", s[:1+size])
+	}
+	b.WriteRune(rune(code))
+	return 1 + size, nil
+}
+
+// yamlFlowComplete reports whether all flow collections opened in s are closed.
+func yamlFlowComplete(s string) bool {
+	depth := 0
+	var quote byte
+	last := byte('[')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				if quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+					i++
+				} else {
+					quote = 0
+				}
+			}
+		case c == '#' && i > 0 && (s[i-1] == ' ' || s[i-1] == '\t' || s[i-1] == '\n'):
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case (c == '"' || c == '\'') && strings.IndexByte("[{,:", last) >= 0:
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+		if c != ' ' && c != '\t' && c != '\n' {
+			last = c
+		}
+	}
+	return depth <= 0 && quote == 0
+}
+
+// yamlFlow parses flow collections.
+type yamlFlow struct {
+	s    string
+	pos  int
+	line int
+}
+
+// skipSpace moves behind white space, line breaks and comments.
+func (f *yamlFlow) skipSpace() {
+	for f.pos < len(f.s) {
+		switch f.s[f.pos] {
+		case ' ', '\t', '\n':
+			f.pos++
+		case '#':
+			for f.pos < len(f.s) && f.s[f.pos] != '\n' {
+				f.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// value parses the flow node at the current position.
+func (f *yamlFlow) value(depth int) (interface{}, error) {
+	if depth > maxDecodeDepth {
+		return nil, errDecodeDepth
+	}
+
+	f.skipSpace()
+	if f.pos == len(f.s) {
+		return nil, yamlError(f.line, "unexpected end of flow collection")
+	}
+
+	switch f.s[f.pos] {
+	case '[':
+		f.pos++
+		list := []interface{}{}
+		for {
+			f.skipSpace()
+			if f.pos < len(f.s) && f.s[f.pos] == ']' {
+				f.pos++
+				return list, nil
+			}
+			item, err := f.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+
+	case '{':
+		f.pos++
+		m := make(map[string]interface{})
+		for {
+			f.skipSpace()
+			if f.pos < len(f.s) && f.s[f.pos] == '}' {
+				f.pos++
+				return m, nil
+			}
+			key, err := f.key()
+			if err != nil {
+				return nil, err
+			}
+			if _, duplicate := m[key]; duplicate {
+				return nil, yamlError(f.line, "duplicate key %q", key)
+			}
+
+			f.skipSpace()
+			if f.pos < len(f.s) && f.s[f.pos] == ':' {
+				f.pos++
+				f.skipSpace()
+				if f.pos < len(f.s) && f.s[f.pos] != ',' && f.s[f.pos] != '}' {
+					if m[key], err = f.value(depth + 1); err != nil {
+						return nil, err
+					}
+				} else {
+					m[key] = nil
+				}
+			} else {
+				m[key] = nil
+			}
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+
+	case '"', '\'':
+		value, rest, err := yamlQuoted(f.s[f.pos:], f.line)
+		if err != nil {
+			return nil, err
+		}
+		f.pos = len(f.s) - len(rest)
+		return value, nil
+
+	case '&', '*', '!':
+		return nil, yamlError(f.line, "anchors, aliases and tags are not supported")
+
+	case ']', '}', ',':
+		return nil, yamlError(f.line, "unexpected %q in flow collection", f.s[f.pos])
+	}
+
+	value, err := resolveYAMLScalar(f.plain())
+	if err != nil {
+		return nil, yamlError(f.line, "%v", err)
+	}
+	return value, nil
+}
+
+// key parses the key of a flow mapping entry.
+func (f *yamlFlow) key() (string, error) {
+	f.skipSpace()
+	if f.pos < len(f.s) && (f.s[f.pos] == '"' || f.s[f.pos] == '\'') {
+		key, rest, err := yamlQuoted(f.s[f.pos:], f.line)
+		f.pos = len(f.s) - len(rest)
+		return key, err
+	}
+	if f.pos < len(f.s) && strings.IndexByte("[{&*!?", f.s[f.pos]) >= 0 {
+		return "", yamlError(f.line, "unsupported key in flow mapping")
+	}
+	return f.plain(), nil
+}
+
+// plain reads a plain scalar, which ends at a flow indicator, a comment or a ": ".
+func (f *yamlFlow) plain() string {
+	start := f.pos
+	for f.pos < len(f.s) {
+		c := f.s[f.pos]
+		if c == ',' || c == '[' || c == ']' || c == '{' || c == '}' || c == '\n' {
+			break
+		}
+		if c == '#' && f.pos > start && (f.s[f.pos-1] == ' ' || f.s[f.pos-1] == '\t') {
+			break
+		}
+		if c == ':' && (f.pos+1 == len(f.s) || strings.IndexByte(" \t\n,[]{}", f.s[f.pos+1]) >= 0) {
+			break
+		}
+		f.pos++
+	}
+	return strings.TrimRight(f.s[start:f.pos], " \t")
+}
+
+// separator consumes the "," between entries or the closing bracket, which it leaves for the caller.
+func (f *yamlFlow) separator(closing byte) error {
+	f.skipSpace()
+	if f.pos < len(f.s) {
+		switch f.s[f.pos] {
+		case ',':
+			f.pos++
+			return nil
+		case closing:
+			return nil
+		}
+	}
+	return yamlError(f.line, "expected ',' or '%c' in flow collection", closing)
+}
@@ -0,0 +1,137 @@
+package toolkit
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "empty", input: "", want: nil},
+		{name: "scalar", input: "hello", want: "hello"},
+		{name: "scalars", input: "a: 1\nb: -2.5\nc: true\nd: ~\ne: 0x1f\nf: 'it''s'\ng: \"tab\\there\"\nh: http://example.com\n", want: map[string]interface{}{
+			"a": int64(1), "b": -2.5, "c": true, "d": nil, "e": uint64(31), "f": "it's", "g": "tab\there", "h": "http://example.com",
+		}},
+		{name: "comments", input: "# header\na: value # comment\nb: \"quoted # not a comment\"\n", want: map[string]interface{}{
+			"a": "value", "b": "quoted # not a comment",
+		}},
+		{name: "nested", input: "---\nserver:\n  host: localhost\n  ports:\n    - 80\n    - 443\n", want: map[string]interface{}{
+			"server": map[string]interface{}{"host": "localhost", "ports": []interface{}{int64(80), int64(443)}},
+		}},
+		{name: "sequence at key indent", input: "items:\n- a\n- b\nnext: c\n", want: map[string]interface{}{
+			"items": []interface{}{"a", "b"}, "next": "c",
+		}},
+		{name: "mappings in sequence", input: "- name: a\n  value: 1\n- name: b\n  value: 2\n", want: []interface{}{
+			map[string]interface{}{"name": "a", "value": int64(1)},
+			map[string]interface{}{"name": "b", "value": int64(2)},
+		}},
+		{name: "nested sequences", input: "- - a\n  - b\n- - c\n", want: []interface{}{
+			[]interface{}{"a", "b"}, []interface{}{"c"},
+		}},
+		{name: "flow", input: "a: [1, two, {b: c, 'd': [ ]}]\ne: {\"f\": 1,\n  g: null}\n", want: map[string]interface{}{
+			"a": []interface{}{int64(1), "two", map[string]interface{}{"b": "c", "d": []interface{}{}}},
+			"e": map[string]interface{}{"f": int64(1), "g": nil},
+		}},
+		{name: "literal", input: "text: |\n  line 1\n    indented\n\n  line 3\nnext: x\n", want: map[string]interface{}{
+			"text": "line 1\n  indented\n\nline 3\n", "next": "x",
+		}},
+		{name: "folded strip", input: "text: >-\n  one\n  two\n\n  three\n", want: map[string]interface{}{
+			"text": "one two\nthree",
+		}},
+		{name: "keep", input: "text: |+\n  one\n\n", want: map[string]interface{}{"text": "one\n\n"}},
+		{name: "empty value", input: "a:\nb: 1\n", want: map[string]interface{}{"a": nil, "b": int64(1)}},
+		{name: "document end", input: "a: 1\n...\n", want: map[string]interface{}{"a": int64(1)}},
+		{name: "duplicate key", input: "a: 1\na: 2\n", wantErr: true},
+		{name: "anchor", input: "a: &x 1\nb: *x\n", wantErr: true},
+		{name: "tag", input: "a: !!str 1\n", wantErr: true},
+		{name: "bad indentation", input: "a: 1\n   b: 2\n", wantErr: true},
+		{name: "tab indentation", input: "a:\n\tb: 1\n", wantErr: true},
+		{name: "two documents", input: "a: 1\n---\nb: 2\n", wantErr: true},
+		{name: "unclosed flow", input: "a: [1, 2\n", wantErr: true},
+		{name: "unterminated quote", input: "a: \"open\n", wantErr: true},
+		{name: "mapping in scalar", input: "a: b: c\n", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := decodeYAML(test.input)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v\n", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v\n", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected %#v, got %#v\n", test.name, test.want, got)
+		}
+	}
+}
+
+func TestEncodeYAML(t *testing.T) {
+	value := map[string]interface{}{
+		"name":  "Widget",
+		"count": 3,
+		"tags":  []string{"true", "a: b", ""},
+		"items": []map[string]interface{}{{"id": 1, "ok": true}, {}},
+		"empty": []int{},
+		"none":  nil,
+	}
+
+	var buf bytes.Buffer
+	if err := YAMLCodec.Encode(&buf, value); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `count: 3
+empty: []
+items:
+- id: 1
+  ok: true
+- {}
+name: Widget
+none: null
+tags:
+- "true"
+- "a: b"
+- ""
+`
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s\n", want, buf.String())
+	}
+}
+
+func TestEncodeYAML11Words(t *testing.T) {
+	words := []string{"yes", "NO", "on", "Off", "y", "N", "null", "~", "True"}
+	for _, word := range words {
+		var buf bytes.Buffer
+		if err := YAMLCodec.Encode(&buf, map[string]string{word: word}); err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf("%q: %q\n", word, word)
+		if buf.String() != want {
+			t.Errorf("%s: expected %q, got %q\n", word, want, buf.String())
+		}
+
+		var decoded map[string]string
+		if err := YAMLCodec.Decode(&buf, &decoded); err != nil || decoded[word] != word {
+			t.Errorf("%s: expected the string back, got %v and %v\n", word, decoded, err)
+		}
+	}
+
+	// Words that are no booleans stay plain.
+	var buf bytes.Buffer
+	_ = YAMLCodec.Encode(&buf, []string{"yesterday", "none", "online"})
+	if want := "- yesterday\n- none\n- online\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q\n", want, buf.String())
+	}
+}
@@ -0,0 +1,175 @@
+package toolkit
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	// ErrZipTooLarge is returned by DownloadZip if the files exceed MaxZipSize.
+	ErrZipTooLarge = errors.New("files exceed the maximum archive size")
+	// ErrInvalidArchiveName is returned by DownloadZip for archive names that are absolute, contain ".."
+	// or are used more than once.
+	ErrInvalidArchiveName = errors.New("invalid or duplicate name in archive")
+)
+
+// ZipEntry is a file to be added to an archive by DownloadZip.
+type ZipEntry struct {
+	// Path is the location of the file, a slash separated path within the file system.
+	Path string
+	// Name is the name of the file within the archive. The default is Path.
+	Name string
+}
+
+// zipFile is a ZipEntry which has been checked and is ready to be written.
+type zipFile struct {
+	name string
+	open func() (io.ReadCloser, error)
+	info fs.FileInfo
+}
+
+// DownloadZip streams a ZIP archive of the given files directly to the client; no temporary files are
+// created. The files are read from fsys or, if fsys is nil, from below DownloadRoot with the same checks
+// as DownloadStaticFileFromRoot. AllowedDownloadExtensions applies to every file. displayName is sent in
+// the Content-Disposition header like for DownloadStaticFile.
+// All files are checked before the response is started, so a missing file (404), a forbidden file (403),
+// an invalid archive name (400) or exceeding MaxZipSize (413) is returned as *DownloadError before
+// anything is written, like by DownloadStaticFileFromRoot. Errors while streaming are returned after the
+// status has been sent; they can only be logged.
+func (t *Tools) DownloadZip(w http.ResponseWriter, r *http.Request, fsys fs.FS, entries []ZipEntry, displayName string) error {
+	files, err := t.prepareZip(fsys, entries)
+	if err != nil {
+		return err
+	}
+
+	w, release, ok := t.limitDownload(w, r)
+	if !ok {
+		return &DownloadError{Name: displayName, Status: http.StatusTooManyRequests, Err: ErrTooManyDownloads}
+	}
+	defer release()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", ContentDisposition(t.disposition(), displayName))
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	zw := zip.NewWriter(w)
+	var total int64
+	for _, file := range files {
+		written, err := t.writeZipFile(zw, file, total)
+		if err != nil {
+			return &DownloadError{Name: file.name, Status: http.StatusInternalServerError, Err: err}
+		}
+		total += written
+	}
+
+	return zw.Close()
+}
+
+// prepareZip checks all entries and the total size before anything is written.
+func (t *Tools) prepareZip(fsys fs.FS, entries []ZipEntry) ([]zipFile, error) {
+	files := make([]zipFile, 0, len(entries))
+	names := make(map[string]bool, len(entries))
+	var total int64
+
+	for _, entry := range entries {
+		name := entry.Name
+		if name == "" {
+			name = entry.Path
+		}
+		name = strings.TrimPrefix(name, "/")
+		if !fs.ValidPath(name) || name == "." || names[name] {
+			return nil, &DownloadError{Name: name, Status: http.StatusBadRequest, Err: ErrInvalidArchiveName}
+		}
+		names[name] = true
+
+		file, err := t.zipSource(fsys, entry.Path)
+		if err != nil {
+			return nil, err
+		}
+		file.name = name
+
+		total += file.info.Size()
+		if t.MaxZipSize > 0 && total > t.MaxZipSize {
+			return nil, &DownloadError{Name: name, Status: http.StatusRequestEntityTooLarge, Err: ErrZipTooLarge}
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// zipSource checks the file p in fsys or below DownloadRoot and returns how to open it.
+func (t *Tools) zipSource(fsys fs.FS, p string) (zipFile, error) {
+	if fsys == nil {
+		filePath, err := t.resolveDownload(p)
+		if err != nil {
+			return zipFile{}, err
+		}
+		fi, err := os.Stat(filePath)
+		if err != nil {
+			return zipFile{}, &DownloadError{Name: p, Status: http.StatusNotFound, Err: err}
+		}
+		return zipFile{info: fi, open: func() (io.ReadCloser, error) { return os.Open(filePath) }}, nil
+	}
+
+	p = strings.TrimPrefix(p, "/")
+	if !fs.ValidPath(p) {
+		return zipFile{}, &DownloadError{Name: p, Status: http.StatusForbidden, Err: ErrOutsideDownloadRoot}
+	}
+	if !t.extensionAllowed(p) {
+		return zipFile{}, &DownloadError{Name: p, Status: http.StatusForbidden, Err: ErrExtensionNotAllowed}
+	}
+	fi, err := fs.Stat(fsys, p)
+	if err != nil {
+		return zipFile{}, &DownloadError{Name: p, Status: http.StatusNotFound, Err: err}
+	}
+	if fi.IsDir() {
+		return zipFile{}, &DownloadError{Name: p, Status: http.StatusNotFound, Err: fs.ErrNotExist}
+	}
+	return zipFile{info: fi, open: func() (io.ReadCloser, error) { return fsys.Open(p) }}, nil
+}
+
+// writeZipFile adds file to the archive and returns the number of bytes written. Files that have grown
+// since they were checked are cut off at MaxZipSize and result in ErrZipTooLarge.
+func (t *Tools) writeZipFile(zw *zip.Writer, file zipFile, written int64) (int64, error) {
+	hdr, err := zip.FileInfoHeader(file.info)
+	if err != nil {
+		return 0, err
+	}
+	hdr.Name = path.Clean(file.name)
+	hdr.Method = zip.Deflate
+
+	out, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return 0, err
+	}
+
+	in, err := file.open()
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	var src io.Reader = in
+	if t.MaxZipSize > 0 {
+		src = io.LimitReader(in, t.MaxZipSize-written+1)
+	}
+	n, err := io.Copy(out, src)
+	if err != nil {
+		return n, err
+	}
+	if t.MaxZipSize > 0 && written+n > t.MaxZipSize {
+		return n, fmt.Errorf("%s: %w", file.name, ErrZipTooLarge)
+	}
+	return n, nil
+}
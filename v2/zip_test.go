@@ -0,0 +1,117 @@
+package toolkit
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDownloadZip(t *testing.T) {
+	fsys := fstest.MapFS{
+		"uploads/a.txt":   {Data: []byte("first file")},
+		"uploads/b.txt":   {Data: []byte("second file")},
+		"uploads/big.bin": {Data: bytes.Repeat([]byte("x"), 100)},
+	}
+
+	var tools Tools
+	rr := httptest.NewRecorder()
+	entries := []ZipEntry{
+		{Path: "uploads/a.txt", Name: "first.txt"},
+		{Path: "uploads/b.txt", Name: "docs/second.txt"},
+		{Path: "uploads/big.bin"},
+	}
+	err := tools.DownloadZip(rr, httptest.NewRequest("GET", "/", nil), fsys, entries, "my files.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := rr.Header().Get("Content-Type"); got != "application/zip" {
+		t.Errorf("Unexpected Content-Type %s\n", got)
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename="my files.zip"` {
+		t.Errorf("Unexpected Content-Disposition %s\n", got)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"first.txt": 10, "docs/second.txt": 11, "uploads/big.bin": 100}
+	if len(zr.File) != len(want) {
+		t.Errorf("Expected %d files in archive, got %d\n", len(want), len(zr.File))
+	}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		if len(data) != want[f.Name] {
+			t.Errorf("%s: expected %d bytes, got %d\n", f.Name, want[f.Name], len(data))
+		}
+	}
+}
+
+func TestDownloadZipErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("0123456789")},
+		"b.txt": {Data: []byte("0123456789")},
+	}
+
+	tests := []struct {
+		name    string
+		maxSize int64
+		entries []ZipEntry
+		status  int
+		err     error
+	}{
+		{name: "too large", maxSize: 15, entries: []ZipEntry{{Path: "a.txt"}, {Path: "b.txt"}}, status: http.StatusRequestEntityTooLarge, err: ErrZipTooLarge},
+		{name: "exactly max size", maxSize: 20, entries: []ZipEntry{{Path: "a.txt"}, {Path: "b.txt"}}, status: http.StatusOK},
+		{name: "duplicate name", entries: []ZipEntry{{Path: "a.txt", Name: "x.txt"}, {Path: "b.txt", Name: "x.txt"}}, status: http.StatusBadRequest, err: ErrInvalidArchiveName},
+		{name: "zip slip", entries: []ZipEntry{{Path: "a.txt", Name: "../../evil.sh"}}, status: http.StatusBadRequest, err: ErrInvalidArchiveName},
+		{name: "traversal", entries: []ZipEntry{{Path: "../a.txt", Name: "a.txt"}}, status: http.StatusForbidden, err: ErrOutsideDownloadRoot},
+		{name: "missing", entries: []ZipEntry{{Path: "c.txt"}}, status: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		tools := Tools{MaxZipSize: test.maxSize}
+		rr := httptest.NewRecorder()
+		err := tools.DownloadZip(rr, httptest.NewRequest("GET", "/", nil), fsys, test.entries, "files.zip")
+
+		if status := downloadStatus(t, rr, err); status != test.status {
+			t.Errorf("%s: expected status %d, got %d\n", test.name, test.status, status)
+		}
+		if test.status != http.StatusOK && err == nil {
+			t.Errorf("%s: expected error but received none\n", test.name)
+		}
+		if test.err != nil && !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v\n", test.name, test.err, err)
+		}
+	}
+}
+
+func TestDownloadZipFromRoot(t *testing.T) {
+	root := setupDownloadRoot(t)
+	tools := Tools{DownloadRoot: root}
+
+	rr := httptest.NewRecorder()
+	err := tools.DownloadZip(rr, httptest.NewRequest("GET", "/", nil), nil, []ZipEntry{{Path: "report.pdf"}, {Path: "docs/a.txt"}}, "files.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len())); err != nil {
+		t.Error(err)
+	}
+
+	rr = httptest.NewRecorder()
+	err = tools.DownloadZip(rr, httptest.NewRequest("GET", "/", nil), nil, []ZipEntry{{Path: "link.txt"}}, "files.zip")
+	if status := downloadStatus(t, rr, err); !errors.Is(err, ErrOutsideDownloadRoot) || status != http.StatusForbidden {
+		t.Errorf("Expected ErrOutsideDownloadRoot and 403, got %v and %d\n", err, status)
+	}
+}